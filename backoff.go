@@ -0,0 +1,54 @@
+package golog
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// ****************************************************************************
+// Backoff computes the delay between attempts to reach a resource that has
+// gone away.  Every call to Next doubles the base delay, up to Max, and the
+// value returned is picked at random from the upper half of that base so that
+// many writers don't hammer a recovering collector in lockstep.
+//
+// A zero Backoff is ready to use and starts at 100ms, capped at 30s.
+//
+type Backoff struct {
+	Min     time.Duration // First delay, before jitter.
+	Max     time.Duration // Upper bound for any delay.
+	attempt uint
+}
+
+func (b *Backoff) Next() time.Duration {
+	min, max := b.Min, b.Max
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	if max < min {
+		max = min
+	}
+
+	d := max
+	if b.attempt < 32 {
+		if shifted := min << b.attempt; shifted > 0 && shifted < max {
+			d = shifted
+		}
+	}
+	b.attempt++
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// Start over from Min, typically after a successful attempt.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,263 @@
+// Benchmarks of the logging path, from the caller's side.  Every benchmark
+// reports allocations, and the ones going through bench report the p50, p99
+// and p99.9 latency of a single logging call on top of the average.
+//
+// Run them with:
+//
+//	go test -run XXX -bench . -benchmem
+//
+package golog
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A writer throwing everything away.
+type discardWriter struct{}
+
+func (discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (discardWriter) Close() error {
+	return nil
+}
+
+func newDiscardLogger(p Priority) *Logger {
+	logger := NewLogger("bench: ")
+	logger.AddProcessor("discard", NewProcessorFromWriter(p, discardWriter{}, true))
+	return logger
+}
+
+// Report the given percentiles of the latencies, which get sorted.
+func reportLatencies(b *testing.B, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	for _, pct := range []struct {
+		unit string
+		at   float64
+	}{{"p50-ns", 0.5}, {"p99-ns", 0.99}, {"p99.9-ns", 0.999}} {
+		b.ReportMetric(float64(latencies[int(float64(len(latencies)-1)*pct.at)]), pct.unit)
+	}
+}
+
+// Time b.N calls to log, one after the other.
+func bench(b *testing.B, log func(i int)) {
+	latencies := make([]time.Duration, b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		log(i)
+		latencies[i] = time.Since(start)
+	}
+	b.StopTimer()
+	reportLatencies(b, latencies)
+}
+
+// Time b.N calls to log, spread over GOMAXPROCS producers.
+func benchParallel(b *testing.B, log func(i int)) {
+	var mu sync.Mutex
+	latencies := make([]time.Duration, 0, b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var own []time.Duration
+		for i := 0; pb.Next(); i++ {
+			start := time.Now()
+			log(i)
+			own = append(own, time.Since(start))
+		}
+		mu.Lock()
+		latencies = append(latencies, own...)
+		mu.Unlock()
+	})
+	b.StopTimer()
+	reportLatencies(b, latencies)
+}
+
+func BenchmarkLogConstant(b *testing.B) {
+	logger := newDiscardLogger(LOG_DEBUG)
+	bench(b, func(int) { logger.Infof("Mmm, cherry blossom tea <3") })
+}
+
+func BenchmarkLogFormatted(b *testing.B) {
+	logger := newDiscardLogger(LOG_DEBUG)
+	bench(b, func(i int) { logger.Infof("request %d took %dms", i, 42) })
+}
+
+func BenchmarkLogWithFields(b *testing.B) {
+	logger := newDiscardLogger(LOG_DEBUG).With(Field{"request", "abc123"}, Field{"user", 42})
+	bench(b, func(i int) { logger.Infof("request %d took %dms", i, 42) })
+}
+
+func BenchmarkLogFilteredOut(b *testing.B) {
+	logger := newDiscardLogger(LOG_ERR)
+	bench(b, func(int) { logger.Debugf("Mmm, cherry blossom tea <3") })
+}
+
+func BenchmarkLogFilteredOutFormatted(b *testing.B) {
+	logger := newDiscardLogger(LOG_ERR)
+	bench(b, func(i int) { logger.Debugf("request %d took %dms", i, 42) })
+}
+
+func BenchmarkLogParallel(b *testing.B) {
+	logger := newDiscardLogger(LOG_DEBUG)
+	benchParallel(b, func(i int) { logger.Infof("request %d took %dms", i, 42) })
+}
+
+func BenchmarkLogParallelFilteredOut(b *testing.B) {
+	logger := newDiscardLogger(LOG_ERR)
+	benchParallel(b, func(i int) { logger.Debugf("request %d took %dms", i, 42) })
+}
+
+// Local sinks for the processors writing over the network, each draining
+// and throwing away whatever it's sent.
+
+func udpSink(b *testing.B) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Skipf("Couldn't listen on UDP: %s", err.Error())
+	}
+	b.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			if _, _, err := conn.ReadFrom(buf); err != nil {
+				return
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func tcpSink(b *testing.B) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Skipf("Couldn't listen on TCP: %s", err.Error())
+	}
+	b.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func httpSink(b *testing.B, response string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		io.WriteString(w, response)
+	}))
+	b.Cleanup(server.Close)
+	return server.URL
+}
+
+func journalSink(b *testing.B) string {
+	path := filepath.Join(b.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		b.Skipf("Couldn't listen on a unixgram socket: %s", err.Error())
+	}
+	b.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	return path
+}
+
+func BenchmarkProcessors(b *testing.B) {
+	processors := []struct {
+		name string
+		new  func(b *testing.B) (LogProcessor, error)
+	}{
+		{"Default", func(*testing.B) (LogProcessor, error) {
+			return NewProcessorFromWriter(LOG_DEBUG, discardWriter{}, true), nil
+		}},
+		{"File", func(b *testing.B) (LogProcessor, error) {
+			return NewFileProcessor(LOG_DEBUG, filepath.Join(b.TempDir(), "bench.log"))
+		}},
+		{"BufferedFile", func(b *testing.B) (LogProcessor, error) {
+			return NewBufferedFileProcessor(LOG_DEBUG, filepath.Join(b.TempDir(), "bench.log"), BufferedOptions{})
+		}},
+		{"Udp", func(b *testing.B) (LogProcessor, error) {
+			return NewUdpProcessorAt(udpSink(b), LOG_DEBUG)
+		}},
+		{"Syslog", func(b *testing.B) (LogProcessor, error) {
+			return NewSyslogProcessorAt("udp", udpSink(b), LOCAL0, LOG_DEBUG)
+		}},
+		{"Gelf", func(b *testing.B) (LogProcessor, error) {
+			return NewGelfProcessorAt(udpSink(b), LOG_DEBUG, GelfOptions{})
+		}},
+		{"Tcp", func(b *testing.B) (LogProcessor, error) {
+			return NewTcpProcessorAt(tcpSink(b), LOG_DEBUG)
+		}},
+		{"Http", func(b *testing.B) (LogProcessor, error) {
+			return NewHttpProcessor(httpSink(b, ""), LOG_DEBUG, HttpOptions{})
+		}},
+		{"Loki", func(b *testing.B) (LogProcessor, error) {
+			return NewLokiProcessor(httpSink(b, ""), LOG_DEBUG, LokiOptions{})
+		}},
+		{"Elastic", func(b *testing.B) (LogProcessor, error) {
+			return NewElasticProcessor(httpSink(b, `{"errors":false}`), LOG_DEBUG, ElasticOptions{})
+		}},
+		{"Journald", func(b *testing.B) (LogProcessor, error) {
+			return NewJournaldProcessorAt(journalSink(b), LOG_DEBUG)
+		}},
+		{"RingBuffer", func(*testing.B) (LogProcessor, error) {
+			return NewRingBufferProcessor(0, LOG_DEBUG), nil
+		}},
+		{"Fanout", func(*testing.B) (LogProcessor, error) {
+			fanout := NewFanoutProcessor()
+			fanout.AddTarget("first", NewLogDispatcher(discardWriter{}), LOG_DEBUG, 0)
+			fanout.AddTarget("second", NewLogDispatcher(discardWriter{}), LOG_DEBUG, 0)
+			return fanout, nil
+		}},
+		{"Filter", func(*testing.B) (LogProcessor, error) {
+			proc := NewProcessorFromWriter(LOG_DEBUG, discardWriter{}, true)
+			return NewFilterProcessor(proc, ExcludeIf(MatchPrefix("access: "))), nil
+		}},
+		{"RateLimit", func(*testing.B) (LogProcessor, error) {
+			proc := NewProcessorFromWriter(LOG_DEBUG, discardWriter{}, true)
+			return NewRateLimitProcessor(proc, RateLimitOptions{Rate: 1e9}), nil
+		}},
+		{"Dedup", func(*testing.B) (LogProcessor, error) {
+			return NewDedupProcessor(NewProcessorFromWriter(LOG_DEBUG, discardWriter{}, true), 0), nil
+		}},
+	}
+
+	for _, p := range processors {
+		b.Run(p.name, func(b *testing.B) {
+			proc, err := p.new(b)
+			if err != nil {
+				b.Skipf("Couldn't create the processor: %s", err.Error())
+			}
+			logger := NewLogger("bench: ").With(Field{"request", "abc123"})
+			logger.AddProcessor(p.name, proc)
+			defer logger.Close()
+
+			bench(b, func(i int) { logger.Infof("request %d took %dms", i, 42) })
+		})
+	}
+}
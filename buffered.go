@@ -0,0 +1,233 @@
+// Buffering layer for writers, mostly files, so that a busy service doesn't
+// make one write system call per message.
+//
+package golog
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBufferSize    = 64 * 1024
+	defaultFlushInterval = time.Second
+	defaultFsyncInterval = time.Second
+)
+
+// When a BufferedWriter asks for its data to be committed to disk.
+type FsyncPolicy int
+
+const (
+	FsyncNever      FsyncPolicy = iota // Leave it to the operating system.
+	FsyncInterval                      // Every FsyncInterval.
+	FsyncOnPriority                    // Right after writing an important enough message.
+)
+
+// Options for the BufferedWriter.  The zero value is usable.
+type BufferedOptions struct {
+	// Messages are buffered until Size bytes, 64KB by default, are
+	// pending or FlushInterval, one second by default, has passed.
+	Size          int
+	FlushInterval time.Duration
+
+	// With FsyncInterval, the buffer is flushed and synced every
+	// FsyncInterval, one second by default.  With FsyncOnPriority, it's
+	// flushed and synced as soon as a message at least as important as
+	// FsyncPriority comes in, LOG_ERR when nil.
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration
+	FsyncPriority *Priority
+}
+
+type syncer interface {
+	Sync() error
+}
+
+// Every BufferedWriter still open, so that FlushLogsAndDie can write out
+// what they hold before the process goes down.
+var (
+	bufferedMu      sync.Mutex
+	bufferedWriters = map[*BufferedWriter]struct{}{}
+)
+
+// Write out and, unless their policy is FsyncNever, sync every open
+// BufferedWriter.
+func syncBufferedWriters() {
+	bufferedMu.Lock()
+	writers := make([]*BufferedWriter, 0, len(bufferedWriters))
+	for bw := range bufferedWriters {
+		writers = append(writers, bw)
+	}
+	bufferedMu.Unlock()
+
+	for _, bw := range writers {
+		if bw.opts.Fsync == FsyncNever {
+			bw.Flush()
+		} else {
+			bw.Sync()
+		}
+	}
+}
+
+// ****************************************************************************
+// The BufferedWriter collects messages in a buffer and writes them out in
+// bulk, either when the buffer fills up or on a timer.  Syncing only
+// happens when the underlying writer has a Sync method, as *os.File does.
+//
+// Dispatchers tell it the priority of messages sent with SendPriority, as
+// the DefaultProcessor does, which is what FsyncOnPriority relies on.
+// FlushLogsAndDie writes out every BufferedWriter still open.
+//
+type BufferedWriter struct {
+	w     io.WriteCloser
+	opts  BufferedOptions
+	fsync Priority   // Resolved FsyncPriority.
+	mu    sync.Mutex // Protects the buffer and everything below.
+	buf   *bufio.Writer
+	dirty bool // Whether anything was flushed since the last sync.
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func (bw *BufferedWriter) Write(data []byte) (n int, err error) {
+	return bw.WritePriority(LOG_DEBUG, data)
+}
+
+func (bw *BufferedWriter) WritePriority(p Priority, data []byte) (n int, err error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if bw.buf == nil {
+		return 0, errWriterClosed
+	}
+	if n, err = bw.buf.Write(data); err != nil {
+		return n, err
+	}
+	if bw.opts.Fsync == FsyncOnPriority && p <= bw.fsync {
+		err = bw.sync()
+	}
+	return n, err
+}
+
+// Write out everything buffered.
+func (bw *BufferedWriter) Flush() error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.flush()
+}
+
+// Write out everything buffered and commit it to disk.
+func (bw *BufferedWriter) Sync() error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.sync()
+}
+
+// Must be called with bw.mu held.
+func (bw *BufferedWriter) flush() error {
+	if bw.buf == nil {
+		return errWriterClosed
+	}
+	if bw.buf.Buffered() > 0 {
+		bw.dirty = true
+	}
+	return bw.buf.Flush()
+}
+
+// Must be called with bw.mu held.
+func (bw *BufferedWriter) sync() error {
+	if err := bw.flush(); err != nil {
+		return err
+	}
+	if s, ok := bw.w.(syncer); ok && bw.dirty {
+		bw.dirty = false
+		return s.Sync()
+	}
+	return nil
+}
+
+func (bw *BufferedWriter) run() {
+	defer bw.wg.Done()
+
+	flushes := time.NewTicker(bw.opts.FlushInterval)
+	defer flushes.Stop()
+	var syncs <-chan time.Time
+	if bw.opts.Fsync == FsyncInterval {
+		ticker := time.NewTicker(bw.opts.FsyncInterval)
+		defer ticker.Stop()
+		syncs = ticker.C
+	}
+
+	for {
+		select {
+		case <-flushes.C:
+			bw.Flush()
+		case <-syncs:
+			bw.Sync()
+		case <-bw.done:
+			return
+		}
+	}
+}
+
+// Write out everything buffered, syncing it unless the policy is
+// FsyncNever, and close the underlying writer.
+func (bw *BufferedWriter) Close() error {
+	bw.mu.Lock()
+	if bw.buf == nil {
+		bw.mu.Unlock()
+		return nil
+	}
+	close(bw.done)
+	var err error
+	if bw.opts.Fsync == FsyncNever {
+		err = bw.flush()
+	} else {
+		err = bw.sync()
+	}
+	bw.buf = nil
+	bw.mu.Unlock()
+
+	bufferedMu.Lock()
+	delete(bufferedWriters, bw)
+	bufferedMu.Unlock()
+
+	bw.wg.Wait()
+	if cerr := bw.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func NewBufferedWriter(w io.WriteCloser, opts BufferedOptions) *BufferedWriter {
+	if opts.Size <= 0 {
+		opts.Size = defaultBufferSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = defaultFsyncInterval
+	}
+	fsync := LOG_ERR
+	if opts.FsyncPriority != nil {
+		fsync = *opts.FsyncPriority
+	}
+
+	bw := &BufferedWriter{
+		w:     w,
+		opts:  opts,
+		fsync: fsync,
+		buf:   bufio.NewWriterSize(w, opts.Size),
+		done:  make(chan struct{}),
+	}
+	bw.wg.Add(1)
+	go bw.run()
+
+	bufferedMu.Lock()
+	bufferedWriters[bw] = struct{}{}
+	bufferedMu.Unlock()
+	return bw
+}
@@ -0,0 +1,137 @@
+package golog
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A writer counting the writes and syncs it gets.
+type syncRecorder struct {
+	mu     sync.Mutex
+	data   bytes.Buffer
+	writes int
+	syncs  int
+}
+
+func (sr *syncRecorder) Write(b []byte) (int, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.writes++
+	return sr.data.Write(b)
+}
+
+func (sr *syncRecorder) Sync() error {
+	sr.mu.Lock()
+	sr.syncs++
+	sr.mu.Unlock()
+	return nil
+}
+
+func (sr *syncRecorder) Close() error {
+	return nil
+}
+
+func (sr *syncRecorder) counts() (data string, writes, syncs int) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.data.String(), sr.writes, sr.syncs
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBufferedWriterSyncsOnPriority(t *testing.T) {
+	sr := &syncRecorder{}
+	bw := NewBufferedWriter(sr, BufferedOptions{FlushInterval: time.Hour, Fsync: FsyncOnPriority})
+	logger := NewLogger("buffered: ")
+	logger.AddProcessor("file", NewProcessorFromWriter(LOG_DEBUG, bw, true))
+
+	for i := 0; i < 10; i++ {
+		logger.Infof("%d", i)
+	}
+	logger.Errorf("disk on fire")
+	waitFor(t, "the error to be synced", func() bool {
+		_, _, syncs := sr.counts()
+		return syncs == 1
+	})
+	data, writes, _ := sr.counts()
+	if writes != 1 || !bytes.HasSuffix([]byte(data), []byte("buffered: disk on fire\n")) {
+		t.Errorf("Expected a single write ending with the error, got %d writes of %q", writes, data)
+	}
+
+	// Closing writes out and syncs whatever is left.
+	bw.Write([]byte("after\n"))
+	if err := bw.Close(); err != nil {
+		t.Fatalf("Couldn't close the writer: %s", err.Error())
+	}
+	if _, writes, syncs := sr.counts(); writes != 2 || syncs != 2 {
+		t.Errorf("Expected 2 writes and 2 syncs after closing, got %d and %d", writes, syncs)
+	}
+}
+
+func TestBufferedWriterFlushesOnTimer(t *testing.T) {
+	sr := &syncRecorder{}
+	bw := NewBufferedWriter(sr, BufferedOptions{FlushInterval: 10 * time.Millisecond, Fsync: FsyncInterval, FsyncInterval: 20 * time.Millisecond})
+	defer bw.Close()
+
+	bw.Write([]byte("first\n"))
+	bw.Write([]byte("second\n"))
+	waitFor(t, "a sync", func() bool {
+		_, _, syncs := sr.counts()
+		return syncs == 1
+	})
+	if data, writes, _ := sr.counts(); writes != 1 || data != "first\nsecond\n" {
+		t.Errorf("Expected one write of both messages, got %d writes of %q", writes, data)
+	}
+}
+
+func TestSyncBufferedWriters(t *testing.T) {
+	never, onError := &syncRecorder{}, &syncRecorder{}
+	bwNever := NewBufferedWriter(never, BufferedOptions{FlushInterval: time.Hour})
+	bwOnError := NewBufferedWriter(onError, BufferedOptions{FlushInterval: time.Hour, Fsync: FsyncOnPriority})
+	bwNever.Write([]byte("never\n"))
+	bwOnError.Write([]byte("on error\n"))
+
+	syncBufferedWriters()
+	if data, _, syncs := never.counts(); data != "never\n" || syncs != 0 {
+		t.Errorf("Expected the data to be written out without syncing, got %q and %d syncs", data, syncs)
+	}
+	if data, _, syncs := onError.counts(); data != "on error\n" || syncs != 1 {
+		t.Errorf("Expected the data to be written out and synced, got %q and %d syncs", data, syncs)
+	}
+
+	// Closed writers are forgotten.
+	bwNever.Close()
+	bwOnError.Close()
+	bufferedMu.Lock()
+	_, found := bufferedWriters[bwNever]
+	bufferedMu.Unlock()
+	if found {
+		t.Errorf("Closed writer is still registered")
+	}
+}
+
+func TestBufferedWriterSyncsOnEmergencyOnly(t *testing.T) {
+	sr := &syncRecorder{}
+	emerg := LOG_EMERG
+	opts := BufferedOptions{FlushInterval: time.Hour, Fsync: FsyncOnPriority, FsyncPriority: &emerg}
+	bw := NewBufferedWriter(sr, opts)
+	defer bw.Close()
+
+	bw.WritePriority(LOG_ERR, []byte("error\n"))
+	if _, _, syncs := sr.counts(); syncs != 0 {
+		t.Errorf("Expected errors not to be synced, got %d syncs", syncs)
+	}
+	bw.WritePriority(LOG_EMERG, []byte("emergency\n"))
+	if _, _, syncs := sr.counts(); syncs != 1 {
+		t.Errorf("Expected the emergency to be synced, got %d syncs", syncs)
+	}
+}
@@ -0,0 +1,122 @@
+// Passing Loggers along with a context.Context, and picking fields such as
+// request or trace IDs out of contexts when logging.
+//
+package golog
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+type loggerKey struct{}
+
+// Get a copy of ctx carrying logger, see FromContext.
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Get the Logger carried by ctx, or a Logger without any processor, which
+// drops everything, if there's none.
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return logger
+	}
+	return NewLogger("")
+}
+
+// Picks fields out of a context for the Ctx logging methods.
+type ContextExtractor func(ctx context.Context) []Field
+
+// An extractor adding a field named name when ctx holds a value for key.
+func ContextValue(key interface{}, name string) ContextExtractor {
+	return func(ctx context.Context) []Field {
+		if value := ctx.Value(key); value != nil {
+			return []Field{{Key: name, Value: value}}
+		}
+		return nil
+	}
+}
+
+var (
+	extractorsMu sync.Mutex                         // Serializes registrations.
+	extractors   atomic.Pointer[[]ContextExtractor] // Never changed once published.
+)
+
+// Have every Ctx logging method add the fields extractor picks out of its
+// context.  Extractors run in the order they were registered, and are
+// meant to be registered once at startup.
+func RegisterContextExtractor(extractor ContextExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	var registered []ContextExtractor
+	if old := extractors.Load(); old != nil {
+		registered = append(registered, *old...)
+	}
+	registered = append(registered, extractor)
+	extractors.Store(&registered)
+}
+
+func contextFields(ctx context.Context) []Field {
+	registered := extractors.Load()
+	if registered == nil {
+		return nil
+	}
+	var fields []Field
+	for _, extract := range *registered {
+		fields = append(fields, extract(ctx)...)
+	}
+	return fields
+}
+
+// The Ctx logging methods work as their counterparts without Ctx, adding
+// the fields the registered extractors pick out of ctx.  Extractors only
+// run if some processor accepts the priority.
+//
+func (dl *Logger) LogfCtx(ctx context.Context, p Priority, format string, args ...interface{}) {
+	dl.logfCtx(1, ctx, p, format, args...)
+}
+
+func (dl *Logger) logfCtx(skip int, ctx context.Context, p Priority, format string, args ...interface{}) {
+	if BoundPriority(p) > dl.GetMaxPriority() {
+		return
+	}
+	logger := dl
+	if fields := contextFields(ctx); len(fields) > 0 {
+		logger = dl.With(fields...)
+	}
+	logger.logf(skip+1, p, format, args...)
+}
+
+func (dl *Logger) DebugfCtx(ctx context.Context, format string, args ...interface{}) {
+	dl.logfCtx(1, ctx, LOG_DEBUG, format, args...)
+}
+
+func (dl *Logger) InfofCtx(ctx context.Context, format string, args ...interface{}) {
+	dl.logfCtx(1, ctx, LOG_INFO, format, args...)
+}
+
+func (dl *Logger) NoticefCtx(ctx context.Context, format string, args ...interface{}) {
+	dl.logfCtx(1, ctx, LOG_NOTICE, format, args...)
+}
+
+func (dl *Logger) WarningfCtx(ctx context.Context, format string, args ...interface{}) {
+	dl.logfCtx(1, ctx, LOG_WARNING, format, args...)
+}
+
+func (dl *Logger) ErrorfCtx(ctx context.Context, format string, args ...interface{}) {
+	dl.logfCtx(1, ctx, LOG_ERR, format, args...)
+}
+
+func (dl *Logger) CriticalfCtx(ctx context.Context, format string, args ...interface{}) {
+	dl.logfCtx(1, ctx, LOG_CRIT, format, args...)
+}
+
+func (dl *Logger) AlertfCtx(ctx context.Context, format string, args ...interface{}) {
+	dl.logfCtx(1, ctx, LOG_ALERT, format, args...)
+}
+
+func (dl *Logger) EmergencyfCtx(ctx context.Context, format string, args ...interface{}) {
+	dl.logfCtx(1, ctx, LOG_EMERG, format, args...)
+}
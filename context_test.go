@@ -0,0 +1,37 @@
+package golog
+
+import (
+	"context"
+	"testing"
+)
+
+type requestIDKey struct{}
+
+func TestContextLogging(t *testing.T) {
+	defer extractors.Store(nil)
+	RegisterContextExtractor(ContextValue(requestIDKey{}, "request_id"))
+
+	ring := NewRingBufferProcessor(10, LOG_INFO)
+	logger := NewLogger("ctx: ").With(Field{"service", "orders"})
+	logger.AddProcessor("ring", ring)
+
+	ctx := NewContext(context.WithValue(context.Background(), requestIDKey{}, "abc123"), logger)
+	FromContext(ctx).InfofCtx(ctx, "order %d saved", 7)
+	FromContext(ctx).DebugfCtx(ctx, "filtered")
+	logger.InfofCtx(context.Background(), "no request")
+
+	entries := ring.Snapshot()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %q", ringMsgs(entries))
+	}
+	fields := entries[0].Fields
+	if entries[0].Msg != "order 7 saved\n" || len(fields) != 2 || fields[1].Key != "request_id" || fields[1].Value != "abc123" {
+		t.Errorf("Unexpected entry %q with fields %v", entries[0].Msg, fields)
+	}
+	if len(entries[1].Fields) != 1 {
+		t.Errorf("Expected only the logger's field without a request, got %v", entries[1].Fields)
+	}
+
+	// Without a logger in the context, entries go nowhere.
+	FromContext(context.Background()).ErrorfCtx(ctx, "dropped")
+}
@@ -0,0 +1,111 @@
+// Flight recorder support: entries kept by a RingBufferProcessor, usually
+// at a much lower priority than anything else logs at, are written out to
+// another processor when the process crashes.
+//
+package golog
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type crashDump struct {
+	ring   *RingBufferProcessor
+	target LogProcessor
+}
+
+var (
+	crashMu    sync.Mutex
+	crashDumps []crashDump
+	crashing   *int32 = new(int32) // Set once the process is going down.
+	dumped     bool                // Crash dumps are only written once.
+
+	// Called to flush logs when crashing, swapped out by tests.
+	crashFlush = FlushLogsAndDie
+)
+
+// Have the entries kept by ring written to target when the process
+// crashes.  Every entry is written regardless of target's priority, so
+// target is typically a file processor dedicated to crash dumps.  This
+// holds for the processors of this package, and the ones wrapping them,
+// other processors only get the entries their priority lets through.
+func DumpOnCrash(ring *RingBufferProcessor, target LogProcessor) {
+	crashMu.Lock()
+	crashDumps = append(crashDumps, crashDump{ring: ring, target: target})
+	crashMu.Unlock()
+}
+
+// Write every ring registered with DumpOnCrash to its target, preceded by
+// a line saying how many entries follow.  Only the first call writes
+// anything, later ones return right away.
+func DumpCrashLogs() {
+	crashMu.Lock()
+	defer crashMu.Unlock()
+	if dumped {
+		return
+	}
+	dumped = true
+
+	for _, dump := range crashDumps {
+		entries := dump.ring.Snapshot()
+		header := &LogEntry{
+			Priority: LOG_CRIT,
+			Msg:      fmt.Sprintf("Crash dump of the last %d log entries follows\n", len(entries)),
+			Created:  time.Now(),
+		}
+
+		processUnfiltered(dump.target, header)
+		for i := range entries {
+			processUnfiltered(dump.target, &entries[i])
+		}
+	}
+}
+
+// Mark the process as crashing and flush the logs, crash dumps included.
+func crash() {
+	atomic.StoreInt32(crashing, 1)
+	crashFlush()
+}
+
+// Write the crash dumps and flush the logs if the calling go routine is
+// panicking, then carry on panicking.  It must be deferred directly:
+//
+//	defer golog.DumpOnPanic()
+//
+func DumpOnPanic() {
+	if r := recover(); r != nil {
+		crash()
+		panic(r)
+	}
+}
+
+// Signals DumpOnSignals listens to when none are given.
+var defaultCrashSignals = []os.Signal{syscall.SIGQUIT, syscall.SIGABRT}
+
+// Write the crash dumps and flush the logs when one of the given signals
+// (SIGQUIT and SIGABRT by default) is received.  The signal is then raised
+// again with its default handling restored, so the process dies the way
+// it would have.
+func DumpOnSignals(sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = defaultCrashSignals
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		sig := <-ch
+		crash()
+		signal.Reset(sigs...)
+		if p, err := os.FindProcess(os.Getpid()); err == nil && p.Signal(sig) == nil {
+			// Give the signal a moment to take us down.
+			time.Sleep(time.Second)
+		}
+		os.Exit(2)
+	}()
+}
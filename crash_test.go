@@ -0,0 +1,113 @@
+package golog
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Forget the registered crash dumps, and that they were written, for the
+// next test.
+func resetCrashState() {
+	crashMu.Lock()
+	defer crashMu.Unlock()
+	atomic.StoreInt32(crashing, 0)
+	crashDumps, dumped = nil, false
+}
+
+func TestDumpOnPanic(t *testing.T) {
+	flushed := false
+	crashFlush = func() {
+		flushed = true
+		DumpCrashLogs()
+	}
+	defer func() {
+		crashFlush = FlushLogsAndDie
+		resetCrashState()
+	}()
+
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	chw := NewChanWriter()
+	target := NewProcessorFromWriter(LOG_ERR, chw, true)
+	DumpOnCrash(ring, target)
+
+	logger := NewLogger("crash_test: ")
+	logger.AddProcessor("ring", ring)
+	logger.AddProcessor("crash", target)
+	logger.Debugf("leading up to it")
+	logger.Infof("almost there")
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Expected the panic to carry on, got %v", r)
+			}
+		}()
+		defer DumpOnPanic()
+		panic("boom")
+	}()
+
+	if !flushed {
+		t.Fatalf("Logs weren't flushed on panic")
+	}
+	if target.GetPriority() != LOG_ERR {
+		t.Errorf("Target's priority was changed by the dump")
+	}
+
+	expected := []string{"Crash dump of the last 2 log entries follows", "leading up to it", "almost there"}
+	for _, msg := range expected {
+		select {
+		case line := <-chw.msg:
+			if !strings.HasSuffix(line, msg+"\n") {
+				t.Errorf("Expected %q, got %q", msg, line)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %q", msg)
+		}
+	}
+
+	// A second crash doesn't dump again.
+	DumpCrashLogs()
+	select {
+	case line := <-chw.msg:
+		t.Errorf("Unexpected line after the dump: %q", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDumpToFanoutKeepsTargetPriorities(t *testing.T) {
+	defer resetCrashState()
+
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	errs, warnings := NewChanWriter(), NewChanWriter()
+	fanout := NewFanoutProcessor()
+	fanout.AddTarget("errors", NewLogDispatcher(errs), LOG_ERR, 0)
+	fanout.AddTarget("warnings", NewLogDispatcher(warnings), LOG_WARNING, 0)
+	defer fanout.Close()
+	DumpOnCrash(ring, fanout)
+
+	logger := NewLogger("crash_test: ")
+	logger.AddProcessor("ring", ring)
+	logger.Debugf("leading up to it")
+	DumpCrashLogs()
+
+	for _, chw := range []*ChanWriter{errs, warnings} {
+		for _, msg := range []string{"Crash dump of the last 1 log entries follows", "leading up to it"} {
+			select {
+			case line := <-chw.msg:
+				if !strings.HasSuffix(line, msg+"\n") {
+					t.Errorf("Expected %q, got %q", msg, line)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for %q", msg)
+			}
+		}
+	}
+	if p, _ := fanout.GetTargetPriority("errors"); p != LOG_ERR {
+		t.Errorf("Expected the errors target to stay at LOG_ERR, got %s", p)
+	}
+	if p, _ := fanout.GetTargetPriority("warnings"); p != LOG_WARNING {
+		t.Errorf("Expected the warnings target to stay at LOG_WARNING, got %s", p)
+	}
+}
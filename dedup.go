@@ -0,0 +1,123 @@
+// Log Processor wrapping another one and collapsing runs of identical
+// entries, the way syslogd does.
+//
+package golog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultDedupWindow = 30 * time.Second
+
+// ****************************************************************************
+// The DedupProcessor passes the first of a run of identical entries (same
+// priority, prefix and message) on to the processor it wraps, and counts
+// the ones that follow it.  The count is written as a "last message
+// repeated N times" entry when a different entry comes in, when the window
+// has passed since the first repeat, and when the processor is closed.
+// An identical entry coming in more than a window after the previous one
+// starts a new run.
+//
+// Priorities, time formats and closing are all handed to the wrapped
+// processor.
+//
+type DedupProcessor struct {
+	LogProcessor
+	window  time.Duration
+	mu      sync.Mutex // Protects everything below.
+	last    LogEntry   // Entry of the current run.
+	seen    time.Time  // When the last entry of the run came in.
+	repeats int
+	flusher *time.Timer
+	closed  bool
+}
+
+func (dp *DedupProcessor) WantsCaller() bool {
+	cp, ok := dp.LogProcessor.(CallerProcessor)
+	return ok && cp.WantsCaller()
+}
+
+// Entries written regardless of priority skip deduplication too.
+func (dp *DedupProcessor) processUnfiltered(entry *LogEntry) {
+	processUnfiltered(dp.LogProcessor, entry)
+}
+
+func (dp *DedupProcessor) Process(entry *LogEntry) {
+	if entry.Priority > dp.GetPriority() {
+		return
+	}
+
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+
+	if !dp.closed && dp.repeated(entry) {
+		dp.repeats++
+		dp.seen = entry.Created
+		if dp.flusher == nil {
+			dp.flusher = time.AfterFunc(dp.window, dp.flushLater)
+		}
+		return
+	}
+
+	dp.flush()
+	dp.last = LogEntry{Prefix: entry.Prefix, Priority: entry.Priority, Msg: entry.Msg}
+	dp.seen = entry.Created
+	dp.LogProcessor.Process(entry)
+}
+
+func (dp *DedupProcessor) repeated(entry *LogEntry) bool {
+	return entry.Priority == dp.last.Priority &&
+		entry.Prefix == dp.last.Prefix &&
+		entry.Msg == dp.last.Msg &&
+		entry.Created.Sub(dp.seen) <= dp.window
+}
+
+// Write the pending count, if any.  Must be called with dp.mu held.
+func (dp *DedupProcessor) flush() {
+	if dp.flusher != nil {
+		dp.flusher.Stop()
+		dp.flusher = nil
+	}
+	if dp.repeats == 0 {
+		return
+	}
+
+	dp.LogProcessor.Process(&LogEntry{
+		Prefix:   dp.last.Prefix,
+		Priority: dp.last.Priority,
+		Msg:      fmt.Sprintf("last message repeated %d times\n", dp.repeats),
+		Created:  time.Now(),
+	})
+	dp.repeats = 0
+}
+
+func (dp *DedupProcessor) flushLater() {
+	dp.mu.Lock()
+	dp.flusher = nil
+	if !dp.closed {
+		dp.flush()
+	}
+	dp.mu.Unlock()
+}
+
+// Write the pending count, then close the wrapped processor.
+func (dp *DedupProcessor) Close() error {
+	dp.mu.Lock()
+	if !dp.closed {
+		dp.flush()
+		dp.closed = true
+	}
+	dp.mu.Unlock()
+	return dp.LogProcessor.Close()
+}
+
+// Create a DedupProcessor collapsing identical entries passed on to proc.
+// The window defaults to 30 seconds if it isn't positive.
+func NewDedupProcessor(proc LogProcessor, window time.Duration) *DedupProcessor {
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	return &DedupProcessor{LogProcessor: proc, window: window}
+}
@@ -0,0 +1,71 @@
+package golog
+
+import (
+	"testing"
+	"time"
+)
+
+// Keeps the wrapped processor open when closed, so that its entries can be
+// looked at afterwards.
+type keepOpen struct {
+	LogProcessor
+}
+
+func (keepOpen) Close() error {
+	return nil
+}
+
+func TestDedupCollapsesRepeats(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	proc := NewDedupProcessor(keepOpen{ring}, time.Minute)
+	logger := NewLogger("dedup: ")
+	logger.AddProcessor("dedup", proc)
+
+	for i := 0; i < 4; i++ {
+		logger.Warningf("retrying")
+	}
+	logger.Errorf("retrying")
+	logger.Errorf("gave up")
+	logger.Errorf("gave up")
+	logger.Close()
+
+	expected := []struct {
+		priority Priority
+		msg      string
+	}{
+		{LOG_WARNING, "retrying\n"},
+		{LOG_WARNING, "last message repeated 3 times\n"},
+		{LOG_ERR, "retrying\n"},
+		{LOG_ERR, "gave up\n"},
+		{LOG_ERR, "last message repeated 1 times\n"},
+	}
+	entries := ring.Snapshot()
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %q", len(expected), ringMsgs(entries))
+	}
+	for i, e := range expected {
+		if entries[i].Priority != e.priority || entries[i].Prefix != "dedup: " || entries[i].Msg != e.msg {
+			t.Errorf("Expected %s %q, got %s %q", e.priority, e.msg, entries[i].Priority, entries[i].Msg)
+		}
+	}
+}
+
+func TestDedupFlushesOnTimer(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	proc := NewDedupProcessor(ring, 20*time.Millisecond)
+	logger := NewLogger("dedup: ")
+	logger.AddProcessor("dedup", proc)
+
+	logger.Infof("polling")
+	logger.Infof("polling")
+	logger.Infof("polling")
+	for deadline := time.Now().Add(time.Second); ring.Len() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the repeat count")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if msgs := ringMsgs(ring.Snapshot()); msgs[1] != "last message repeated 2 times\n" {
+		t.Errorf("Unexpected entries %q", msgs)
+	}
+}
@@ -2,12 +2,59 @@ package golog
 
 import (
 	"io"
+	"sync"
 )
 
+// Messages whose buffer grew larger than this aren't put back in the pool,
+// so that one huge message doesn't keep its memory around for good.
+const maxPooledMsgSize = 64 * 1024
+
 // Object which is sent through the log channel
 type LogMsg struct {
 	w   io.Writer // The writer we'll write msg to on the "other side"
-	msg string    // Log message.
+	msg []byte    // Log message.
+	p   Priority  // Priority of the message, log_DISABLE if unknown.
+}
+
+// LogMsgs are reused once written, along with their buffer.
+var msgPool = sync.Pool{New: func() interface{} { return new(LogMsg) }}
+
+// Scratch buffers used while formatting, see getBuffer and putBuffer.
+var bufPool = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+func getBuffer() *[]byte {
+	return bufPool.Get().(*[]byte)
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledMsgSize {
+		return
+	}
+	*buf = (*buf)[:0]
+	bufPool.Put(buf)
+}
+
+// Writers which handle messages differently depending on their priority
+// implement PriorityWriter.  It's called instead of Write for messages sent
+// with SendPriority or SendBytes.
+type PriorityWriter interface {
+	WritePriority(p Priority, data []byte) (n int, err error)
+}
+
+// Write the message out and put it back in the pool.  Writers must not
+// keep the data they're given, as io.Writer says.
+func (lm *LogMsg) write() {
+	if pw, ok := lm.w.(PriorityWriter); ok && lm.p != log_DISABLE {
+		pw.WritePriority(lm.p, lm.msg)
+	} else {
+		lm.w.Write(lm.msg)
+	}
+
+	if cap(lm.msg) > maxPooledMsgSize {
+		lm.msg = nil
+	}
+	lm.w, lm.msg = nil, lm.msg[:0]
+	msgPool.Put(lm)
 }
 
 // ****************************************************************************
@@ -20,8 +67,36 @@ type LogDispatcher struct {
 }
 
 func (lw *LogDispatcher) Send(message string) {
-	entry := LogMsg{w: lw.w, msg: message}
-	lw.ch <- &entry
+	lm := lw.message(log_DISABLE)
+	lm.msg = append(lm.msg, message...)
+	lw.dispatch(lm)
+}
+
+// Send a message along with its priority, for writers which care about it.
+func (lw *LogDispatcher) SendPriority(p Priority, message string) {
+	lm := lw.message(p)
+	lm.msg = append(lm.msg, message...)
+	lw.dispatch(lm)
+}
+
+// Send a message along with its priority.  The message is copied, so the
+// caller is free to reuse it once SendBytes returns.
+func (lw *LogDispatcher) SendBytes(p Priority, message []byte) {
+	lm := lw.message(p)
+	lm.msg = append(lm.msg, message...)
+	lw.dispatch(lm)
+}
+
+// Get an empty message from the pool, for processors to format into
+// before handing it to dispatch.
+func (lw *LogDispatcher) message(p Priority) *LogMsg {
+	lm := msgPool.Get().(*LogMsg)
+	lm.w, lm.p = lw.w, p
+	return lm
+}
+
+func (lw *LogDispatcher) dispatch(lm *LogMsg) {
+	lw.ch <- lm
 }
 
 func (lw *LogDispatcher) Close() error {
@@ -0,0 +1,165 @@
+// Log Processor for indexing entries into Elasticsearch or OpenSearch
+// through the _bulk API, built on top of the HttpWriter.
+//
+package golog
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	elasticBulkPath          = "/_bulk"
+	defaultElasticIndex      = "logs-"
+	defaultElasticDateFormat = "2006.01.02"
+)
+
+// Options for the ElasticProcessor.  The zero value is usable.
+type ElasticOptions struct {
+	HttpOptions
+
+	// Entries go to the index IndexPrefix followed by the entry's UTC date
+	// in IndexDateFormat, "logs-2006.01.02" by default.
+	IndexPrefix     string
+	IndexDateFormat string
+
+	// Document keys for the standard parts of an entry.  They default to
+	// "@timestamp", "level", "prefix" and "message".  Entry fields are
+	// added under their own keys.
+	TimestampField string
+	LevelField     string
+	PrefixField    string
+	MessageField   string
+}
+
+// ****************************************************************************
+// The ElasticProcessor turns every entry into a _bulk index action followed
+// by its document.  Bulk requests can partially fail, so the response is
+// checked item by item, and only the documents refused with a retryable
+// status (429 or 5xx) are sent again.
+//
+type ElasticProcessor struct {
+	*DefaultProcessor
+	writer *HttpWriter
+	opts   ElasticOptions
+}
+
+func (ep *ElasticProcessor) Process(entry *LogEntry) {
+	if entry.Priority <= ep.GetPriority() {
+		ep.processUnfiltered(entry)
+	}
+}
+
+func (ep *ElasticProcessor) processUnfiltered(entry *LogEntry) {
+	ep.Dispatcher.SendBytes(entry.Priority, ep.item(entry))
+}
+
+// Get the counters of the underlying HttpWriter.
+func (ep *ElasticProcessor) Stats() HttpStats {
+	return ep.writer.Stats()
+}
+
+func (ep *ElasticProcessor) item(entry *LogEntry) []byte {
+	timeFormat := ep.GetTimeFormat()
+	if timeFormat == "" {
+		timeFormat = defaultJSONTimeFormat
+	}
+
+	index := ep.opts.IndexPrefix + entry.Created.UTC().Format(ep.opts.IndexDateFormat)
+	buf := append([]byte(nil), `{"index":{"_index":`...)
+	buf = appendJSONString(buf, index)
+	buf = append(buf, "}}\n{"...)
+
+	buf = appendJSONString(buf, ep.opts.TimestampField)
+	buf = append(buf, ':')
+	buf = appendJSONString(buf, entry.Created.Format(timeFormat))
+	buf = append(buf, ',')
+	buf = appendJSONString(buf, ep.opts.LevelField)
+	buf = append(buf, ':')
+	buf = appendJSONString(buf, entry.Priority.String())
+	if entry.Prefix != "" {
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, ep.opts.PrefixField)
+		buf = append(buf, ':')
+		buf = appendJSONString(buf, entry.Prefix)
+	}
+	buf = append(buf, ',')
+	buf = appendJSONString(buf, ep.opts.MessageField)
+	buf = append(buf, ':')
+	buf = appendJSONString(buf, strings.TrimRight(entry.Msg, "\n"))
+	for _, field := range entry.Fields {
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, field.Key)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, field.Value)
+	}
+	return append(buf, "}\n"...)
+}
+
+type elasticBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+	} `json:"items"`
+}
+
+// Pick the items of a bulk request that should be retried out of its
+// response.  Items come back in the order they were sent.
+func checkElasticBulk(resp *http.Response, items [][]byte) (retry [][]byte, rejected int) {
+	var bulk elasticBulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&bulk); err != nil || !bulk.Errors {
+		return nil, 0
+	}
+
+	for i, result := range bulk.Items {
+		if i >= len(items) {
+			break
+		}
+		for _, action := range result {
+			switch {
+			case action.Status == http.StatusTooManyRequests || action.Status >= 500:
+				retry = append(retry, items[i])
+			case action.Status >= 300:
+				rejected++
+			}
+		}
+	}
+	return retry, rejected
+}
+
+// Create an ElasticProcessor indexing into the cluster at baseURL, such as
+// "http://elasticsearch:9200".
+func NewElasticProcessor(baseURL string, p Priority, opts ElasticOptions) (LogProcessor, error) {
+	if opts.IndexPrefix == "" {
+		opts.IndexPrefix = defaultElasticIndex
+	}
+	if opts.IndexDateFormat == "" {
+		opts.IndexDateFormat = defaultElasticDateFormat
+	}
+	if opts.TimestampField == "" {
+		opts.TimestampField = "@timestamp"
+	}
+	if opts.LevelField == "" {
+		opts.LevelField = "level"
+	}
+	if opts.PrefixField == "" {
+		opts.PrefixField = "prefix"
+	}
+	if opts.MessageField == "" {
+		opts.MessageField = "message"
+	}
+
+	httpOpts := opts.HttpOptions
+	httpOpts.ContentType = "application/x-ndjson"
+	httpOpts.Encode = concatEntries
+	httpOpts.Check = checkElasticBulk
+
+	hw, err := NewHttpWriter(strings.TrimRight(baseURL, "/")+elasticBulkPath, httpOpts)
+	if err != nil {
+		return nil, err
+	}
+	dsp := NewLogDispatcher(hw)
+	defaultProcessor := NewProcessor(p, dsp, true).(*DefaultProcessor)
+	return &ElasticProcessor{DefaultProcessor: defaultProcessor, writer: hw, opts: opts}, nil
+}
@@ -0,0 +1,104 @@
+package golog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A stub _bulk endpoint which refuses the items it's told to, only once.
+type fakeBulk struct {
+	refuse map[string]int // Message -> status of its first attempt.
+	bodies chan string
+}
+
+func (fb *fakeBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, _ := ioutil.ReadAll(r.Body)
+	fb.bodies <- r.URL.Path + "\n" + string(data)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var items []string
+	errors := false
+	for i := 1; i < len(lines); i += 2 {
+		doc := map[string]interface{}{}
+		json.Unmarshal([]byte(lines[i]), &doc)
+		status := http.StatusCreated
+		if refused, ok := fb.refuse[fmt.Sprint(doc["msg"])]; ok {
+			status = refused
+			delete(fb.refuse, fmt.Sprint(doc["msg"]))
+			errors = true
+		}
+		items = append(items, fmt.Sprintf(`{"index":{"status":%d}}`, status))
+	}
+	fmt.Fprintf(w, `{"took":1,"errors":%t,"items":[%s]}`, errors, strings.Join(items, ","))
+}
+
+func TestElasticBulkRetriesFailedItems(t *testing.T) {
+	fb := &fakeBulk{
+		refuse: map[string]int{"busy": http.StatusTooManyRequests, "bad": http.StatusBadRequest},
+		bodies: make(chan string, 4),
+	}
+	server := httptest.NewServer(fb)
+	defer server.Close()
+
+	opts := ElasticOptions{
+		HttpOptions:  HttpOptions{MaxBatchEntries: 3, MinBackoff: time.Millisecond},
+		IndexPrefix:  "app-",
+		MessageField: "msg",
+	}
+	proc, err := NewElasticProcessor(server.URL, LOG_INFO, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create elastic processor: %s", err.Error())
+	}
+	logger := NewLogger("es_test: ")
+	logger.AddProcessor("es", proc)
+
+	logger.Infof("ok")
+	logger.Warningf("busy")
+	logger.With(Field{"code", 7}).Errorf("bad")
+
+	var first, second string
+	for _, body := range []*string{&first, &second} {
+		select {
+		case *body = <-fb.bodies:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for a bulk request")
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(first), "\n")
+	if lines[0] != elasticBulkPath || len(lines) != 7 {
+		t.Fatalf("Unexpected first bulk request:\n%s", first)
+	}
+	index := "app-" + time.Now().UTC().Format(defaultElasticDateFormat)
+	if lines[1] != `{"index":{"_index":"`+index+`"}}` {
+		t.Errorf("Unexpected action line %s", lines[1])
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[6]), &doc); err != nil {
+		t.Fatalf("Invalid document %s", lines[6])
+	}
+	if doc["level"] != "ERROR" || doc["prefix"] != "es_test: " || doc["msg"] != "bad" || doc["code"] != float64(7) {
+		t.Errorf("Unexpected document %v", doc)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(doc["@timestamp"])); err != nil {
+		t.Errorf("Unexpected @timestamp %v", doc["@timestamp"])
+	}
+
+	lines = strings.Split(strings.TrimSpace(second), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], `"msg":"busy"`) {
+		t.Errorf("Expected only the busy document to be retried, got:\n%s", second)
+	}
+
+	// Closing waits for the retried request to be handled.
+	logger.Close()
+	stats := proc.(*ElasticProcessor).Stats()
+	if stats.Entries != 2 || stats.Failed != 1 || stats.Retries != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
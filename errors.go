@@ -0,0 +1,200 @@
+// Structured logging of errors: what they say, what they wrap, and where
+// they came from when that's known.
+//
+package golog
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// Errors carrying the stack trace of where they were created implement
+// StackError, as the ones of github.com/go-errors/errors do.
+type StackError interface {
+	Stack() []byte
+}
+
+// Details of an error, kept as the value of the Field made by Err.  JSON
+// processors write it out as an object, the DefaultProcessor as indented
+// lines under the message, the GelfProcessor as additional fields (see
+// addGelfError), and the SyslogProcessor as text shaped like a
+// structured data element (see appendErrorSD).  Other processors write its
+// message.
+type ErrorDetails struct {
+	Message string   `json:"message"`         // err.Error()
+	Types   []string `json:"types"`           // Type of err and of every error it wraps, outermost first.
+	Chain   []string `json:"chain,omitempty"` // Message of every error err wraps, outermost first.
+	Stack   string   `json:"stack,omitempty"` // Stack trace, if err has one or one was captured.
+}
+
+func (ed ErrorDetails) String() string {
+	return ed.Message
+}
+
+// Gather the details of err, following both Unwrap() error and Unwrap()
+// []error.  The stack trace is the one of the first error in the chain
+// implementing StackError, if any.
+func NewErrorDetails(err error) ErrorDetails {
+	details := ErrorDetails{Message: fmt.Sprint(err)}
+	var walk func(err error, depth int)
+	walk = func(err error, depth int) {
+		if err == nil {
+			return
+		}
+		details.Types = append(details.Types, fmt.Sprintf("%T", err))
+		if depth > 0 {
+			details.Chain = append(details.Chain, err.Error())
+		}
+		if se, ok := err.(StackError); ok && details.Stack == "" {
+			details.Stack = string(se.Stack())
+		}
+
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, wrapped := range multi.Unwrap() {
+				walk(wrapped, depth+1)
+			}
+		} else {
+			walk(errors.Unwrap(err), depth+1)
+		}
+	}
+	walk(err, 0)
+	return details
+}
+
+// A Field named "error" holding the details of err.
+func Err(err error) Field {
+	return Field{Key: "error", Value: NewErrorDetails(err)}
+}
+
+// Create a Logger attaching the details of err to every entry it logs,
+// such as logger.Err(err).Errorf("Couldn't save order %d", id).
+func (dl *Logger) Err(err error) *Logger {
+	return dl.With(Err(err))
+}
+
+// Have entries at least as important as p get a stack trace of the logging
+// call for the errors they hold which don't carry one.  Stack traces aren't
+// captured by default.  Loggers created with With share the setting.
+func (dl *Logger) CaptureStacks(p Priority) {
+	dl.stacks.Store(int32(BoundPriority(p)))
+}
+
+// Fill in the stacks of the errors held by fields, copying them if need be.
+func withStacks(fields []Field) []Field {
+	var stack string
+	copied := false
+	for i, field := range fields {
+		details, ok := field.Value.(ErrorDetails)
+		if !ok || details.Stack != "" {
+			continue
+		}
+		if !copied {
+			fields = append([]Field(nil), fields...)
+			stack, copied = string(debug.Stack()), true
+		}
+		details.Stack = stack
+		fields[i].Value = details
+	}
+	return fields
+}
+
+// Append the errors held by fields as indented lines, the way the
+// DefaultProcessor writes them under the message.
+func appendErrorLines(buf []byte, fields []Field) []byte {
+	for _, field := range fields {
+		details, ok := field.Value.(ErrorDetails)
+		if !ok {
+			continue
+		}
+		buf = append(buf, '\t')
+		buf = append(buf, field.Key...)
+		buf = append(buf, ": "...)
+		buf = append(buf, details.Message...)
+		buf = append(buf, "\n\ttypes: "...)
+		buf = append(buf, strings.Join(details.Types, ", ")...)
+		buf = append(buf, '\n')
+		for _, cause := range details.Chain {
+			buf = append(buf, "\tcaused by: "...)
+			buf = append(buf, cause...)
+			buf = append(buf, '\n')
+		}
+		if details.Stack != "" {
+			buf = append(buf, "\tstack:\n"...)
+			for _, line := range strings.Split(strings.TrimRight(details.Stack, "\n"), "\n") {
+				buf = append(buf, "\t\t"...)
+				buf = append(buf, line...)
+				buf = append(buf, '\n')
+			}
+		}
+	}
+	return buf
+}
+
+// Add the details of the error held by the field named key to a GELF
+// document, as the additional field of that name holding the message,
+// along with its "_types", "_chain" and "_stack" variants when there's
+// something to put in them.  Nothing is added if GELF doesn't allow the
+// name.
+func addGelfError(doc map[string]interface{}, key string, details ErrorDetails) {
+	name, ok := gelfFieldName(key)
+	if !ok {
+		return
+	}
+	doc[name] = details.Message
+	doc[name+"_types"] = strings.Join(details.Types, ", ")
+	if len(details.Chain) > 0 {
+		doc[name+"_chain"] = strings.Join(details.Chain, "\n")
+	}
+	if details.Stack != "" {
+		doc[name+"_stack"] = details.Stack
+	}
+}
+
+// Structured data ID used for errors.  32473 is the enterprise number RFC
+// 5424 sets aside for examples, as golog doesn't have one of its own.
+const errorSDID = "error@32473"
+
+// Append the errors held by fields as text shaped like RFC 5424 structured
+// data elements, with the field's key as a "field" parameter, the message
+// as "message", and every type, cause and the stack as repeated "type",
+// "cause" and "stack" parameters.  The SyslogProcessor writes RFC 3164
+// messages, so this is only plain text at the start of the message, which
+// receivers won't pick up as structured data on their own.  Newlines are
+// escaped, so that a stack trace doesn't break the message up into many
+// over newline framed transports.
+func appendErrorSD(buf []byte, fields []Field) []byte {
+	for _, field := range fields {
+		details, ok := field.Value.(ErrorDetails)
+		if !ok {
+			continue
+		}
+		buf = append(buf, "["+errorSDID...)
+		buf = appendSDParam(buf, "field", field.Key)
+		buf = appendSDParam(buf, "message", details.Message)
+		for _, t := range details.Types {
+			buf = appendSDParam(buf, "type", t)
+		}
+		for _, cause := range details.Chain {
+			buf = appendSDParam(buf, "cause", cause)
+		}
+		if details.Stack != "" {
+			buf = appendSDParam(buf, "stack", details.Stack)
+		}
+		buf = append(buf, ']')
+	}
+	return buf
+}
+
+// Param values escape '"', '\' and ']' with a backslash, as RFC 5424 has
+// it, and newlines as \n and \r.
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`, "\n", `\n`, "\r", `\r`)
+
+func appendSDParam(buf []byte, name, value string) []byte {
+	buf = append(buf, ' ')
+	buf = append(buf, name...)
+	buf = append(buf, `="`...)
+	buf = append(buf, sdEscaper.Replace(value)...)
+	return append(buf, '"')
+}
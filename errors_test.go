@@ -0,0 +1,127 @@
+package golog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type stackedError struct{}
+
+func (stackedError) Error() string {
+	return "stacked"
+}
+
+func (stackedError) Stack() []byte {
+	return []byte("main.main()\n\tmain.go:12\n")
+}
+
+func TestErrorDetailsChain(t *testing.T) {
+	err := fmt.Errorf("saving order: %w", &fs.PathError{Op: "open", Path: "/orders", Err: syscall.EACCES})
+	details := NewErrorDetails(err)
+
+	if details.Message != err.Error() {
+		t.Errorf("Unexpected message %q", details.Message)
+	}
+	types := []string{"*fmt.wrapError", "*fs.PathError", "syscall.Errno"}
+	if !reflect.DeepEqual(details.Types, types) {
+		t.Errorf("Expected types %q, got %q", types, details.Types)
+	}
+	chain := []string{"open /orders: permission denied", "permission denied"}
+	if !reflect.DeepEqual(details.Chain, chain) {
+		t.Errorf("Expected chain %q, got %q", chain, details.Chain)
+	}
+	if details.Stack != "" {
+		t.Errorf("Unexpected stack %q", details.Stack)
+	}
+
+	joined := NewErrorDetails(errors.Join(errors.New("first"), fmt.Errorf("second: %w", stackedError{})))
+	if !reflect.DeepEqual(joined.Chain, []string{"first", "second: stacked", "stacked"}) {
+		t.Errorf("Unexpected chain of joined errors %q", joined.Chain)
+	}
+	if joined.Stack != "main.main()\n\tmain.go:12\n" {
+		t.Errorf("Expected the wrapped error's stack, got %q", joined.Stack)
+	}
+}
+
+func TestErrorRendering(t *testing.T) {
+	err := fmt.Errorf("saving order: %w", stackedError{})
+	entry := &LogEntry{
+		Priority: LOG_ERR,
+		Msg:      "failed\n",
+		Created:  time.Now(),
+		Fields:   []Field{Err(err)},
+	}
+
+	text := string(appendEntry(nil, entry, ""))
+	expected := "ERROR: failed\n" +
+		"\terror: saving order: stacked\n" +
+		"\ttypes: *fmt.wrapError, golog.stackedError\n" +
+		"\tcaused by: stacked\n" +
+		"\tstack:\n" +
+		"\t\tmain.main()\n" +
+		"\t\t\tmain.go:12\n"
+	if !strings.HasSuffix(text, expected) {
+		t.Errorf("Unexpected text:\n%s", text)
+	}
+
+	var doc struct {
+		Error ErrorDetails `json:"error"`
+	}
+	if err := json.Unmarshal(appendJSONEntry(nil, entry, ""), &doc); err != nil {
+		t.Fatalf("Invalid JSON: %s", err.Error())
+	}
+	if !reflect.DeepEqual(doc.Error, NewErrorDetails(err)) {
+		t.Errorf("Unexpected JSON error %+v", doc.Error)
+	}
+
+	sd := string(appendErrorSD(nil, entry.Fields))
+	expectedSD := `[error@32473 field="error" message="saving order: stacked" type="*fmt.wrapError" ` +
+		`type="golog.stackedError" cause="stacked" stack="main.main()\n` + "\t" + `main.go:12\n"]`
+	if sd != expectedSD {
+		t.Errorf("Expected structured data %q, got %q", expectedSD, sd)
+	}
+
+	gelf := map[string]interface{}{}
+	addGelfError(gelf, "error", NewErrorDetails(err))
+	expectedGelf := map[string]interface{}{
+		"_error":       "saving order: stacked",
+		"_error_types": "*fmt.wrapError, golog.stackedError",
+		"_error_chain": "stacked",
+		"_error_stack": "main.main()\n\tmain.go:12\n",
+	}
+	if !reflect.DeepEqual(gelf, expectedGelf) {
+		t.Errorf("Unexpected GELF fields %q", gelf)
+	}
+}
+
+func TestCaptureStacks(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	logger := NewLogger("errors: ")
+	logger.AddProcessor("ring", ring)
+	logger.CaptureStacks(LOG_ERR)
+
+	failing := logger.Err(errors.New("boom"))
+	failing.Warningf("no stack")
+	failing.Errorf("with a stack")
+
+	entries := ring.Snapshot()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if stack := entries[0].Fields[0].Value.(ErrorDetails).Stack; stack != "" {
+		t.Errorf("Unexpected stack for a warning %q", stack)
+	}
+	if stack := entries[1].Fields[0].Value.(ErrorDetails).Stack; !strings.Contains(stack, "TestCaptureStacks") {
+		t.Errorf("Expected a stack of the logging call, got %q", stack)
+	}
+	if stack := failing.fields[0].Value.(ErrorDetails).Stack; stack != "" {
+		t.Errorf("Capturing a stack changed the logger's fields")
+	}
+}
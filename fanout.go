@@ -0,0 +1,298 @@
+// Log Processor formatting each entry once and sending the same line to
+// several targets, each with its own priority.
+//
+package golog
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultFanoutQueueSize = logQueueSize
+
+// Counters kept for each target of a FanoutProcessor.
+type FanoutStats struct {
+	Written uint64 // Lines written to the target.
+	Dropped uint64 // Lines dropped because the target's queue was full.
+	Errors  uint64 // Lines the target failed to write.
+}
+
+// A line waiting in a queueWriter, with its priority if known.
+type queuedLine struct {
+	p    Priority
+	data []byte
+}
+
+// ****************************************************************************
+// The queueWriter hands lines over to a go routine of its own, so that a
+// slow or stuck writer only holds up its own queue instead of the global
+// log channel.  Lines are dropped once the queue is full.  Priorities are
+// passed on to writers implementing PriorityWriter.
+//
+type queueWriter struct {
+	w      io.WriteCloser
+	mu     sync.Mutex // Protects closed against Write.
+	closed bool
+	queue  chan queuedLine
+	done   chan struct{}
+	stats  FanoutStats
+}
+
+func (qw *queueWriter) Write(data []byte) (n int, err error) {
+	return qw.WritePriority(log_DISABLE, data)
+}
+
+func (qw *queueWriter) WritePriority(p Priority, data []byte) (n int, err error) {
+	qw.mu.Lock()
+	defer qw.mu.Unlock()
+
+	if qw.closed {
+		return 0, errWriterClosed
+	}
+	select {
+	case qw.queue <- queuedLine{p: p, data: append([]byte(nil), data...)}:
+	default:
+		atomic.AddUint64(&qw.stats.Dropped, 1)
+	}
+	return len(data), nil
+}
+
+// Wait for the queued lines to be written, then close the writer.
+func (qw *queueWriter) Close() error {
+	qw.mu.Lock()
+	if qw.closed {
+		qw.mu.Unlock()
+		return nil
+	}
+	qw.closed = true
+	close(qw.queue)
+	qw.mu.Unlock()
+
+	<-qw.done
+	return qw.w.Close()
+}
+
+func (qw *queueWriter) Stats() FanoutStats {
+	return FanoutStats{
+		Written: atomic.LoadUint64(&qw.stats.Written),
+		Dropped: atomic.LoadUint64(&qw.stats.Dropped),
+		Errors:  atomic.LoadUint64(&qw.stats.Errors),
+	}
+}
+
+func (qw *queueWriter) run() {
+	defer close(qw.done)
+	pw, _ := qw.w.(PriorityWriter)
+	for line := range qw.queue {
+		var err error
+		if pw != nil && line.p != log_DISABLE {
+			_, err = pw.WritePriority(line.p, line.data)
+		} else {
+			_, err = qw.w.Write(line.data)
+		}
+		if err != nil {
+			atomic.AddUint64(&qw.stats.Errors, 1)
+		} else {
+			atomic.AddUint64(&qw.stats.Written, 1)
+		}
+	}
+}
+
+func newQueueWriter(w io.WriteCloser, size int) *queueWriter {
+	if size <= 0 {
+		size = defaultFanoutQueueSize
+	}
+	qw := &queueWriter{w: w, queue: make(chan queuedLine, size), done: make(chan struct{})}
+	go qw.run()
+	return qw
+}
+
+type fanoutTarget struct {
+	name       string
+	priority   atomic.Int32
+	writer     *queueWriter
+	dispatcher *LogDispatcher
+}
+
+// Targets and time format of a FanoutProcessor.  It's never changed once
+// published, so that Process doesn't take any lock.
+type fanoutConfig struct {
+	targets    []*fanoutTarget
+	timeFormat string
+}
+
+// ****************************************************************************
+// The FanoutProcessor formats entries the way the DefaultProcessor does, but
+// only once, and sends the line to every target whose priority accepts it.
+// Each target writes from its own queue, so one failing or falling behind
+// doesn't hold up the others.
+//
+// The processor's own priority is the lowest of its targets' priorities,
+// and setting it sets every target's.
+//
+type FanoutProcessor struct {
+	mu     sync.Mutex // Serializes updates to the config.
+	config atomic.Pointer[fanoutConfig]
+}
+
+func (fp *FanoutProcessor) loadConfig() *fanoutConfig {
+	if config := fp.config.Load(); config != nil {
+		return config
+	}
+	return &fanoutConfig{}
+}
+
+func (fp *FanoutProcessor) target(name string) *fanoutTarget {
+	for _, t := range fp.loadConfig().targets {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Add a target writing with the given dispatcher's writer.  Its lines are
+// queued, up to queueSize of them (512 if queueSize isn't positive), before
+// going through the log channel.  Adding a target under a name already
+// used replaces and closes the old one.
+func (fp *FanoutProcessor) AddTarget(name string, dsp *LogDispatcher, p Priority, queueSize int) {
+	qw := newQueueWriter(dsp.w, queueSize)
+	target := &fanoutTarget{name: name, writer: qw, dispatcher: NewLogDispatcher(qw)}
+	target.priority.Store(int32(BoundPriority(p)))
+
+	fp.mu.Lock()
+	config := *fp.loadConfig()
+	config.targets = append([]*fanoutTarget(nil), config.targets...)
+	var old *fanoutTarget
+	for i, t := range config.targets {
+		if t.name == name {
+			old, config.targets[i] = t, target
+			break
+		}
+	}
+	if old == nil {
+		config.targets = append(config.targets, target)
+	}
+	fp.config.Store(&config)
+	fp.mu.Unlock()
+
+	if old != nil {
+		old.dispatcher.Close()
+	}
+}
+
+// Remove and close the target with the given name.
+func (fp *FanoutProcessor) RemoveTarget(name string) error {
+	fp.mu.Lock()
+	config := *fp.loadConfig()
+	for i, t := range config.targets {
+		if t.name == name {
+			config.targets = append(config.targets[:i:i], config.targets[i+1:]...)
+			fp.config.Store(&config)
+			fp.mu.Unlock()
+			return t.dispatcher.Close()
+		}
+	}
+	fp.mu.Unlock()
+	return errors.New("Couldn't find fanout target with name '" + name + "'")
+}
+
+func (fp *FanoutProcessor) SetTargetPriority(name string, p Priority) error {
+	if t := fp.target(name); t != nil {
+		t.priority.Store(int32(BoundPriority(p)))
+		return nil
+	}
+	return errors.New("Couldn't find fanout target with name '" + name + "'")
+}
+
+func (fp *FanoutProcessor) GetTargetPriority(name string) (Priority, error) {
+	if t := fp.target(name); t != nil {
+		return Priority(t.priority.Load()), nil
+	}
+	return log_DISABLE, errors.New("Couldn't find fanout target with name '" + name + "'")
+}
+
+// Get the counters of every target, by name.
+func (fp *FanoutProcessor) Stats() map[string]FanoutStats {
+	targets := fp.loadConfig().targets
+	stats := make(map[string]FanoutStats, len(targets))
+	for _, t := range targets {
+		stats[t.name] = t.writer.Stats()
+	}
+	return stats
+}
+
+func (fp *FanoutProcessor) SetPriority(p Priority) {
+	p = BoundPriority(p)
+	for _, t := range fp.loadConfig().targets {
+		t.priority.Store(int32(p))
+	}
+}
+
+func (fp *FanoutProcessor) GetPriority() Priority {
+	max := log_DISABLE
+	for _, t := range fp.loadConfig().targets {
+		if p := Priority(t.priority.Load()); p > max {
+			max = p
+		}
+	}
+	return max
+}
+
+func (fp *FanoutProcessor) SetTimeFormat(timeFormat string) {
+	fp.mu.Lock()
+	config := *fp.loadConfig()
+	config.timeFormat = timeFormat
+	fp.config.Store(&config)
+	fp.mu.Unlock()
+}
+
+func (fp *FanoutProcessor) Process(entry *LogEntry) {
+	fp.send(entry, false)
+}
+
+// Write entry to every target, whatever their priority.
+func (fp *FanoutProcessor) processUnfiltered(entry *LogEntry) {
+	fp.send(entry, true)
+}
+
+func (fp *FanoutProcessor) send(entry *LogEntry, unfiltered bool) {
+	config := fp.loadConfig()
+
+	var buf *[]byte
+	for _, t := range config.targets {
+		if unfiltered || entry.Priority <= Priority(t.priority.Load()) {
+			if buf == nil {
+				buf = getBuffer()
+				*buf = appendEntry(*buf, entry, config.timeFormat)
+			}
+			t.dispatcher.SendBytes(entry.Priority, *buf)
+		}
+	}
+	if buf != nil {
+		putBuffer(buf)
+	}
+}
+
+// Close every target, waiting for their queued lines to be written.
+func (fp *FanoutProcessor) Close() error {
+	fp.mu.Lock()
+	targets := fp.loadConfig().targets
+	fp.config.Store(&fanoutConfig{timeFormat: fp.loadConfig().timeFormat})
+	fp.mu.Unlock()
+
+	var err error
+	for _, t := range targets {
+		if cerr := t.dispatcher.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Create a FanoutProcessor without any target, see AddTarget.
+func NewFanoutProcessor() *FanoutProcessor {
+	return &FanoutProcessor{}
+}
@@ -0,0 +1,115 @@
+package golog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// A writer which doesn't return until it's released.
+type stuckWriter struct {
+	release chan struct{}
+}
+
+func (sw *stuckWriter) Write(b []byte) (int, error) {
+	<-sw.release
+	return len(b), nil
+}
+
+func (sw *stuckWriter) Close() error {
+	return nil
+}
+
+func TestFanoutTargetPriorities(t *testing.T) {
+	debug, errs := NewChanWriter(), NewChanWriter()
+	fanout := NewFanoutProcessor()
+	fanout.AddTarget("debug", NewLogDispatcher(debug), LOG_DEBUG, 0)
+	fanout.AddTarget("errors", NewLogDispatcher(errs), LOG_ERR, 0)
+	if fanout.GetPriority() != LOG_DEBUG {
+		t.Errorf("Expected the fanout's priority to be LOG_DEBUG, got %s", fanout.GetPriority())
+	}
+
+	logger := NewLogger("fanout: ")
+	logger.AddProcessor("fanout", fanout)
+	logger.Infof("just so you know")
+	logger.Errorf("something broke")
+
+	expect := func(chw *ChanWriter, msg string) {
+		select {
+		case line := <-chw.msg:
+			if !strings.HasSuffix(line, "fanout: "+msg+"\n") {
+				t.Errorf("Expected %q, got %q", msg, line)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %q", msg)
+		}
+	}
+	expect(debug, "just so you know")
+	expect(debug, "something broke")
+	expect(errs, "something broke")
+
+	if err := fanout.SetTargetPriority("errors", LOG_INFO); err != nil {
+		t.Fatalf("Couldn't set the target's priority: %s", err.Error())
+	}
+	logger.Infof("now for everyone")
+	expect(debug, "now for everyone")
+	expect(errs, "now for everyone")
+
+	if err := fanout.SetTargetPriority("missing", LOG_INFO); err == nil {
+		t.Errorf("Expected an error setting the priority of a missing target")
+	}
+	logger.Close()
+}
+
+func TestFanoutStuckTargetDoesNotBlockOthers(t *testing.T) {
+	stuck := &stuckWriter{release: make(chan struct{})}
+	chw := NewChanWriter()
+	fanout := NewFanoutProcessor()
+	fanout.AddTarget("stuck", NewLogDispatcher(stuck), LOG_DEBUG, 1)
+	fanout.AddTarget("chan", NewLogDispatcher(chw), LOG_DEBUG, 0)
+
+	logger := NewLogger("fanout: ")
+	logger.AddProcessor("fanout", fanout)
+	for i := 0; i < 5; i++ {
+		logger.Infof("line %d", i)
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case <-chw.msg:
+		case <-time.After(time.Second):
+			t.Fatalf("Stuck target held up the others")
+		}
+	}
+
+	// One line is being written, at most one more fits in the queue.
+	if dropped := fanout.Stats()["stuck"].Dropped; dropped < 3 {
+		t.Errorf("Expected the stuck target to drop at least 3 lines, dropped %d", dropped)
+	}
+
+	close(stuck.release)
+	logger.Close()
+	if stats := fanout.Stats(); len(stats) != 0 {
+		t.Errorf("Expected closed targets to be removed, got %v", stats)
+	}
+}
+
+func TestFanoutPassesPrioritiesOn(t *testing.T) {
+	sr := &syncRecorder{}
+	bw := NewBufferedWriter(sr, BufferedOptions{FlushInterval: time.Hour, Fsync: FsyncOnPriority})
+	fanout := NewFanoutProcessor()
+	fanout.AddTarget("file", NewLogDispatcher(bw), LOG_DEBUG, 0)
+
+	logger := NewLogger("fanout: ")
+	logger.AddProcessor("fanout", fanout)
+	defer logger.Close()
+
+	logger.Infof("buffered")
+	logger.Errorf("synced")
+	waitFor(t, "the error to be synced", func() bool {
+		_, _, syncs := sr.counts()
+		return syncs == 1
+	})
+	if data, _, _ := sr.counts(); !strings.HasSuffix(data, "fanout: synced\n") {
+		t.Errorf("Expected both lines to be written out, got %q", data)
+	}
+}
@@ -0,0 +1,75 @@
+// Logging methods which end the program, or at least the current go
+// routine, once the message was written.
+//
+package golog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Called by the Fatal methods once the logs are flushed, os.Exit by default.
+// Tests can replace it to keep the process alive.
+var ExitFunc = os.Exit
+
+// Called by the Panic methods once the logs are flushed with the message
+// logged, the builtin panic by default.
+var PanicFunc = func(v interface{}) { panic(v) }
+
+// The Fatal methods log at LOG_CRIT, write the crash dumps registered with
+// DumpOnCrash, wait for every message to be written, close the Logger's
+// processors so that whatever they buffer gets written too, write out
+// every BufferedWriter, and finally call ExitFunc(1).  Processors of other
+// Loggers aren't closed, so batches they hold back (HttpProcessor, ...)
+// are lost unless they're closed beforehand.
+//
+func (dl *Logger) Fatalf(format string, args ...interface{}) {
+	dl.logf(1, LOG_CRIT, format, args...)
+	dl.exit()
+}
+
+func (dl *Logger) Fatal(args ...interface{}) {
+	dl.log(1, modePrint, LOG_CRIT, args)
+	dl.exit()
+}
+
+func (dl *Logger) Fatalln(args ...interface{}) {
+	dl.log(1, modePrintln, LOG_CRIT, args)
+	dl.exit()
+}
+
+func (dl *Logger) exit() {
+	DumpCrashLogs()
+	FlushLogs()
+	dl.Close()
+	syncBufferedWriters()
+	ExitFunc(1)
+}
+
+// The Panic methods log at LOG_CRIT, wait for the message to be written,
+// then call PanicFunc with the message.  Processors are left open, as the
+// panic may be recovered from.
+//
+func (dl *Logger) Panicf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	dl.logf(1, LOG_CRIT, "%s", msg)
+	dl.panic(msg)
+}
+
+func (dl *Logger) Panic(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	dl.logf(1, LOG_CRIT, "%s", msg)
+	dl.panic(msg)
+}
+
+func (dl *Logger) Panicln(args ...interface{}) {
+	msg := fmt.Sprintln(args...)
+	dl.logf(1, LOG_CRIT, "%s", msg)
+	dl.panic(msg)
+}
+
+func (dl *Logger) panic(msg string) {
+	FlushLogs()
+	PanicFunc(strings.TrimRight(msg, "\n"))
+}
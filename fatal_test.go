@@ -0,0 +1,91 @@
+package golog
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Collects the lines written to it, they can be read once it's closed.
+type collectWriter struct {
+	lines  []string
+	closed bool
+}
+
+func (cw *collectWriter) Write(b []byte) (int, error) {
+	cw.lines = append(cw.lines, string(b))
+	return len(b), nil
+}
+
+func (cw *collectWriter) Close() error {
+	cw.closed = true
+	return nil
+}
+
+func TestFatalfFlushesThenExits(t *testing.T) {
+	var code int
+	ExitFunc = func(c int) { code = c }
+	defer func() {
+		ExitFunc = os.Exit
+		resetCrashState()
+	}()
+
+	cw := &collectWriter{}
+	logger := NewLogger("fatal: ")
+	logger.AddProcessor("collect", NewProcessorFromWriter(LOG_INFO, cw, true))
+	logger.Infof("starting")
+	logger.Fatalf("config %s is missing", "app.conf")
+
+	if code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+	if !cw.closed {
+		t.Errorf("Processors weren't closed before exiting")
+	}
+	if len(cw.lines) != 2 || !strings.HasSuffix(cw.lines[1], "CRTCL: fatal: config app.conf is missing\n") {
+		t.Errorf("Expected both lines to be written before exiting, got %q", cw.lines)
+	}
+}
+
+func TestFatalWritesOutBufferedWriters(t *testing.T) {
+	ExitFunc = func(int) {}
+	defer func() {
+		ExitFunc = os.Exit
+		resetCrashState()
+	}()
+
+	// Buffered on a Logger of its own, which Fatal doesn't close.
+	sr := &syncRecorder{}
+	bw := NewBufferedWriter(sr, BufferedOptions{FlushInterval: time.Hour})
+	defer bw.Close()
+	other := NewLogger("other: ")
+	other.AddProcessor("file", NewProcessorFromWriter(LOG_INFO, bw, true))
+	other.Infof("still buffered")
+
+	NewLogger("fatal: ").Fatal("giving up")
+	if data, _, _ := sr.counts(); !strings.HasSuffix(data, "other: still buffered\n") {
+		t.Errorf("Expected the buffered line to be written out before exiting, got %q", data)
+	}
+}
+
+func TestPanicfFlushesThenPanics(t *testing.T) {
+	var value interface{}
+	PanicFunc = func(v interface{}) { value = v }
+	defer func() { PanicFunc = func(v interface{}) { panic(v) } }()
+
+	cw := &collectWriter{}
+	logger := NewLogger("panic: ")
+	logger.AddProcessor("collect", NewProcessorFromWriter(LOG_INFO, cw, true))
+	logger.Panicf("%d%% of the disk is used", 100)
+
+	if value != "100% of the disk is used" {
+		t.Errorf("Unexpected panic value %v", value)
+	}
+	if cw.closed {
+		t.Errorf("Processors were closed on panic")
+	}
+	if len(cw.lines) != 1 || !strings.HasSuffix(cw.lines[0], "CRTCL: panic: 100% of the disk is used\n") {
+		t.Errorf("Expected the line to be written before panicking, got %q", cw.lines)
+	}
+}
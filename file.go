@@ -46,3 +46,12 @@ func NewFileProcessor(priority Priority, filename string) (LogProcessor, error)
 	}
 	return NewProcessorFromWriter(priority, w, true), nil
 }
+
+// Create a processor writing to filename through a BufferedWriter.
+func NewBufferedFileProcessor(priority Priority, filename string, opts BufferedOptions) (LogProcessor, error) {
+	w, err := openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewProcessorFromWriter(priority, NewBufferedWriter(w, opts), true), nil
+}
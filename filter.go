@@ -0,0 +1,133 @@
+// Log Processor wrapping another one and only letting through the entries
+// chosen by a chain of rules.
+//
+package golog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Matchers used to build FilterRules.
+type FilterMatcher func(entry *LogEntry) bool
+
+// Entries whose message matches re.  The message still has its trailing
+// newline.
+func MatchMsg(re *regexp.Regexp) FilterMatcher {
+	return func(entry *LogEntry) bool { return re.MatchString(entry.Msg) }
+}
+
+// Entries whose prefix starts with prefix.
+func MatchPrefix(prefix string) FilterMatcher {
+	return func(entry *LogEntry) bool { return strings.HasPrefix(entry.Prefix, prefix) }
+}
+
+// Entries with a field named key whose value prints as value.
+func MatchField(key, value string) FilterMatcher {
+	return func(entry *LogEntry) bool {
+		for _, field := range entry.Fields {
+			if field.Key == key && fmt.Sprint(field.Value) == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Entries with a priority between a and b, both included, whichever order
+// they're given in.
+func MatchPriorities(a, b Priority) FilterMatcher {
+	if a > b {
+		a, b = b, a
+	}
+	return func(entry *LogEntry) bool { return entry.Priority >= a && entry.Priority <= b }
+}
+
+// A rule of a FilterProcessor, applying to the entries every one of its
+// matchers matches.
+type FilterRule struct {
+	Exclude  bool // Whether the entries matched are dropped or let through.
+	Matchers []FilterMatcher
+}
+
+// A rule letting through the entries every matcher matches.
+func IncludeIf(matchers ...FilterMatcher) FilterRule {
+	return FilterRule{Matchers: matchers}
+}
+
+// A rule dropping the entries every matcher matches.
+func ExcludeIf(matchers ...FilterMatcher) FilterRule {
+	return FilterRule{Exclude: true, Matchers: matchers}
+}
+
+func (fr *FilterRule) matches(entry *LogEntry) bool {
+	for _, match := range fr.Matchers {
+		if !match(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// ****************************************************************************
+// The FilterProcessor checks entries against its rules in order, and the
+// first rule matching an entry decides whether it's passed on to the
+// wrapped processor or dropped.  Entries no rule matches are passed on,
+// unless the last rule is an ExcludeIf without matchers, which matches
+// everything.
+//
+// Priorities, time formats and closing are all handed to the wrapped
+// processor.
+//
+type FilterProcessor struct {
+	LogProcessor
+	rules atomic.Pointer[[]FilterRule] // Never changed once published.
+}
+
+// Replace the rules.  Entries being processed while the rules are
+// replaced are checked against either the old or the new rules.
+func (fp *FilterProcessor) SetRules(rules ...FilterRule) {
+	rules = append([]FilterRule(nil), rules...)
+	fp.rules.Store(&rules)
+}
+
+func (fp *FilterProcessor) Rules() []FilterRule {
+	return append([]FilterRule(nil), *fp.rules.Load()...)
+}
+
+func (fp *FilterProcessor) WantsCaller() bool {
+	cp, ok := fp.LogProcessor.(CallerProcessor)
+	return ok && cp.WantsCaller()
+}
+
+// Entries written regardless of priority skip the rules too.
+func (fp *FilterProcessor) processUnfiltered(entry *LogEntry) {
+	processUnfiltered(fp.LogProcessor, entry)
+}
+
+func (fp *FilterProcessor) Process(entry *LogEntry) {
+	if entry.Priority > fp.GetPriority() {
+		return
+	}
+
+	rules := *fp.rules.Load()
+	for i := range rules {
+		if rules[i].matches(entry) {
+			if rules[i].Exclude {
+				return
+			}
+			break
+		}
+	}
+	fp.LogProcessor.Process(entry)
+}
+
+// Create a FilterProcessor passing the entries its rules let through on
+// to proc.
+func NewFilterProcessor(proc LogProcessor, rules ...FilterRule) *FilterProcessor {
+	fp := &FilterProcessor{LogProcessor: proc}
+	fp.SetRules(rules...)
+	return fp
+}
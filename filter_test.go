@@ -4,6 +4,7 @@
 package golog
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -68,3 +69,82 @@ func TestFilteredPriorities(t *testing.T) {
 		checkFiltersForPriority(p, logger, chw, t)
 	}
 }
+
+func TestFilterProcessorRules(t *testing.T) {
+	chw := NewChanWriter()
+	proc := NewFilterProcessor(NewProcessorFromWriter(LOG_DEBUG, chw, true),
+		ExcludeIf(MatchPrefix("access: "), MatchMsg(regexp.MustCompile(`GET /health `))),
+		IncludeIf(MatchField("route", "billing")),
+		ExcludeIf(MatchPriorities(LOG_DEBUG, LOG_NOTICE)),
+	)
+	logger := NewLogger("app: ")
+	logger.AddProcessor("filter", proc)
+
+	access := NewLogger("access: ")
+	access.AddProcessor("filter", proc)
+	access.Infof("GET /health 200")
+	access.Warningf("GET /orders 500")
+	logger.Infof("dropped for its priority")
+	logger.With(Field{"route", "billing"}).Debugf("kept for its field")
+	logger.Errorf("kept for its priority")
+
+	expected := []string{"access: GET /orders 500", "app: kept for its field", "app: kept for its priority"}
+	for _, msg := range expected {
+		select {
+		case line := <-chw.msg:
+			if !strings.HasSuffix(line, msg+"\n") {
+				t.Errorf("Expected %q, got %q", msg, line)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %q", msg)
+		}
+	}
+
+	// Replacing the rules takes effect right away.
+	proc.SetRules(ExcludeIf())
+	logger.Errorf("everything is dropped now")
+	select {
+	case line := <-chw.msg:
+		t.Errorf("Unexpected line %q", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestReconfigureWhileLogging(t *testing.T) {
+	ring := NewRingBufferProcessor(16, LOG_DEBUG)
+	proc := NewProcessorFromWriter(LOG_DEBUG, discardWriter{}, true).(*DefaultProcessor)
+	logger := NewLogger("reconfigure: ")
+	logger.AddProcessor("discard", proc)
+	logger.AddProcessor("ring", ring)
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 1000; i++ {
+			logger.Infof("%d", i)
+		}
+		close(done)
+	}()
+	for i := 0; i < 1000; i++ {
+		proc.SetPriority(Priorities()[i%len(Priorities())])
+		proc.SetTimeFormat(time.RFC3339)
+		ring.SetPriority(LOG_INFO)
+	}
+	<-done
+
+	chw := NewChanWriter()
+	proc = NewProcessorFromWriter(LOG_DEBUG, chw, true).(*DefaultProcessor)
+	proc.SetFormatter(func(buf []byte, entry *LogEntry, timeFormat string) []byte {
+		buf = append(buf, entry.Priority.String()...)
+		buf = append(buf, ' ')
+		return append(buf, entry.Msg...)
+	})
+	proc.Process(&LogEntry{Priority: LOG_NOTICE, Msg: "formatted\n", Created: time.Now()})
+	select {
+	case line := <-chw.msg:
+		if line != "NOTICE formatted\n" {
+			t.Errorf("Expected the formatter to be used, got %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the entry")
+	}
+}
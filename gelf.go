@@ -0,0 +1,295 @@
+// Log Processor for sending GELF 1.1 messages to Graylog, either as UDP
+// datagrams, optionally compressed and chunked, or over TCP where messages
+// are separated by a null byte.
+//
+package golog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type GelfCompression int
+
+const (
+	GelfNoCompression GelfCompression = iota
+	GelfGzip
+	GelfZlib
+)
+
+const (
+	gelfVersion          = "1.1"
+	defaultGelfChunkSize = 1420 // Fits an ethernet frame with room for headers.
+	gelfChunkHeaderLen   = 12
+	gelfMaxChunks        = 128
+)
+
+var (
+	gelfChunkMagic      = []byte{0x1e, 0x0f}
+	gelfFieldNameRegexp = regexp.MustCompile(`^[\w\.\-]+$`)
+	errGelfTooLarge     = errors.New("golog: GELF message needs more than 128 chunks")
+)
+
+// Options for the GELF processors.  The zero value is usable.
+type GelfOptions struct {
+	// Sent as "host", defaults to os.Hostname().
+	Host string
+
+	// Additional fields added to every message.  Names get the leading
+	// underscore GELF requires if they don't have one already.  Names GELF
+	// doesn't allow, including "id", are skipped.  The Fields of entries
+	// are added the same way, taking precedence over these.
+	Fields map[string]interface{}
+
+	// UDP only.  Compression applied to each message, and the largest
+	// datagram sent before a message gets split into chunks.
+	Compression GelfCompression
+	ChunkSize   int
+
+	// TCP only.  Timeouts of the connection to Graylog, and how messages
+	// are held back while it's unreachable.
+	Tcp TcpOptions
+}
+
+// ****************************************************************************
+// The GelfProcessor formats entries as GELF JSON documents.  The priority
+// maps directly onto GELF's level since both use syslog severities.
+//
+type GelfProcessor struct {
+	*DefaultProcessor
+	host   string
+	fields map[string]interface{}
+}
+
+func (gp *GelfProcessor) Process(entry *LogEntry) {
+	if entry.Priority <= gp.GetPriority() {
+		gp.processUnfiltered(entry)
+	}
+}
+
+func (gp *GelfProcessor) processUnfiltered(entry *LogEntry) {
+	gp.Dispatcher.SendBytes(entry.Priority, gp.format(entry))
+}
+
+func (gp *GelfProcessor) format(entry *LogEntry) []byte {
+	msg := strings.TrimRight(entry.Msg, "\n")
+	short := msg
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		short = msg[:i]
+	}
+	if short == "" {
+		// GELF requires a non empty short_message.
+		short = "-"
+	}
+
+	doc := make(map[string]interface{}, len(gp.fields)+7)
+	for name, value := range gp.fields {
+		doc[name] = value
+	}
+	for _, field := range entry.Fields {
+		if details, ok := field.Value.(ErrorDetails); ok {
+			addGelfError(doc, field.Key, details)
+		} else if name, ok := gelfFieldName(field.Key); ok {
+			doc[name] = gelfFieldValue(field.Value)
+		}
+	}
+	doc["version"] = gelfVersion
+	doc["host"] = gp.host
+	doc["short_message"] = short
+	if short != msg {
+		doc["full_message"] = msg
+	}
+	doc["timestamp"] = float64(entry.Created.UnixNano()/int64(1e6)) / 1e3
+	doc["level"] = int(entry.Priority)
+	if entry.Prefix != "" {
+		doc["_prefix"] = entry.Prefix
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		// Only an additional field can fail to marshal, so drop them.
+		for name := range doc {
+			if strings.HasPrefix(name, "_") {
+				delete(doc, name)
+			}
+		}
+		data, _ = json.Marshal(doc)
+	}
+	return data
+}
+
+// Name of the additional field for name, with the leading underscore GELF
+// requires, or false if GELF doesn't allow it.
+func gelfFieldName(name string) (string, bool) {
+	name = strings.TrimPrefix(name, "_")
+	if name == "id" || !gelfFieldNameRegexp.MatchString(name) {
+		return "", false
+	}
+	return "_" + name, true
+}
+
+// Values of additional fields can only be strings or numbers in GELF, so
+// anything else is written as fmt.Sprint would.
+func gelfFieldValue(value interface{}) interface{} {
+	switch value.(type) {
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return value
+	}
+	return fmt.Sprint(value)
+}
+
+func newGelfProcessor(w io.WriteCloser, p Priority, opts GelfOptions) *GelfProcessor {
+	host := opts.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	fields := map[string]interface{}{}
+	for name, value := range opts.Fields {
+		if name, ok := gelfFieldName(name); ok {
+			fields[name] = value
+		}
+	}
+
+	dsp := NewLogDispatcher(w)
+	defaultProcessor := NewProcessor(p, dsp, true).(*DefaultProcessor)
+	return &GelfProcessor{DefaultProcessor: defaultProcessor, host: host, fields: fields}
+}
+
+// ****************************************************************************
+// The GelfUdpWriter compresses each message it's given and sends it as a
+// single datagram, or as a series of GELF chunks if it's too large.
+//
+type GelfUdpWriter struct {
+	conn        net.Conn
+	compression GelfCompression
+	chunkSize   int
+}
+
+func (gw *GelfUdpWriter) Write(data []byte) (n int, err error) {
+	payload, err := gw.compress(data)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(payload) <= gw.chunkSize {
+		if _, err = gw.conn.Write(payload); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	perChunk := gw.chunkSize - gelfChunkHeaderLen
+	count := (len(payload) + perChunk - 1) / perChunk
+	if count > gelfMaxChunks {
+		return 0, errGelfTooLarge
+	}
+
+	chunk := make([]byte, gw.chunkSize)
+	copy(chunk, gelfChunkMagic)
+	binary.BigEndian.PutUint64(chunk[2:], rand.Uint64())
+	chunk[11] = byte(count)
+	for seq := 0; seq < count; seq++ {
+		chunk[10] = byte(seq)
+		end := (seq + 1) * perChunk
+		if end > len(payload) {
+			end = len(payload)
+		}
+		size := gelfChunkHeaderLen + copy(chunk[gelfChunkHeaderLen:], payload[seq*perChunk:end])
+		if _, err = gw.conn.Write(chunk[:size]); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (gw *GelfUdpWriter) compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var zw io.WriteCloser
+	switch gw.compression {
+	case GelfGzip:
+		zw = gzip.NewWriter(&buf)
+	case GelfZlib:
+		zw = zlib.NewWriter(&buf)
+	default:
+		return data, nil
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gw *GelfUdpWriter) Close() error {
+	return gw.conn.Close()
+}
+
+func DialGelfUdp(host string, compression GelfCompression, chunkSize int) (*GelfUdpWriter, error) {
+	if chunkSize <= gelfChunkHeaderLen {
+		chunkSize = defaultGelfChunkSize
+	}
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		return nil, err
+	}
+	return &GelfUdpWriter{conn: conn, compression: compression, chunkSize: chunkSize}, nil
+}
+
+// ****************************************************************************
+// The GelfTcpWriter terminates every message with a null byte, as GELF over
+// TCP requires, and reconnects to Graylog when the connection drops.  Like
+// the TcpWriter, every write gets a deadline so that an input which stopped
+// reading can't stall the log channel.
+//
+type GelfTcpWriter struct {
+	*reconnectWriter
+}
+
+func (gw *GelfTcpWriter) Write(data []byte) (n int, err error) {
+	framed := make([]byte, len(data)+1)
+	copy(framed, data)
+	if _, err = gw.reconnectWriter.Write(framed); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func DialGelfTcp(host string, opts TcpOptions) (*GelfTcpWriter, error) {
+	rw, err := newReconnectWriter(tcpDialer(host, opts), opts.ReconnectOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &GelfTcpWriter{reconnectWriter: rw}, nil
+}
+
+// Initializers for the GelfProcessor
+//
+func NewGelfProcessorAt(host string, p Priority, opts GelfOptions) (LogProcessor, error) {
+	gw, err := DialGelfUdp(host, opts.Compression, opts.ChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return newGelfProcessor(gw, p, opts), nil
+}
+
+func NewGelfTcpProcessorAt(host string, p Priority, opts GelfOptions) (LogProcessor, error) {
+	gw, err := DialGelfTcp(host, opts.Tcp)
+	if err != nil {
+		return nil, err
+	}
+	return newGelfProcessor(gw, p, opts), nil
+}
@@ -0,0 +1,235 @@
+package golog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Read one GELF message off c, putting chunks back together.
+func readGelfDatagram(c net.PacketConn, t *testing.T) []byte {
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	chunks := map[byte][]byte{}
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := c.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Couldn't read GELF datagram: %s", err.Error())
+		}
+		data := append([]byte(nil), buf[:n]...)
+		if !bytes.HasPrefix(data, gelfChunkMagic) {
+			return data
+		}
+		chunks[data[10]] = data[gelfChunkHeaderLen:]
+		if count := int(data[11]); len(chunks) == count {
+			var msg []byte
+			for i := 0; i < count; i++ {
+				msg = append(msg, chunks[byte(i)]...)
+			}
+			return msg
+		}
+	}
+}
+
+func decodeGelf(data []byte, t *testing.T) map[string]interface{} {
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Invalid GELF JSON %q: %s", data, err.Error())
+	}
+	return doc
+}
+
+func createGelfLogger(host string, opts GelfOptions, t *testing.T) *Logger {
+	proc, err := NewGelfProcessorAt(host, LOG_DEBUG, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create GELF processor: %s", err.Error())
+	}
+	logger := NewLogger("gelf_test: ")
+	logger.AddProcessor("gelf", proc)
+	return logger
+}
+
+func TestGelfUdpMessage(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start udp listener: %s", err.Error())
+	}
+	defer c.Close()
+
+	opts := GelfOptions{Host: "testhost", Fields: map[string]interface{}{"env": "test", "_id": "skipped"}}
+	logger := createGelfLogger(c.LocalAddr().String(), opts, t)
+	defer logger.Close()
+
+	logger.Warningf("first line\nsecond line")
+	doc := decodeGelf(readGelfDatagram(c, t), t)
+
+	expected := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "testhost",
+		"short_message": "first line",
+		"full_message":  "first line\nsecond line",
+		"level":         float64(LOG_WARNING),
+		"_prefix":       "gelf_test: ",
+		"_env":          "test",
+	}
+	for k, v := range expected {
+		if doc[k] != v {
+			t.Errorf("Unexpected %s: expected %v, got %v", k, v, doc[k])
+		}
+	}
+	if _, ok := doc["_id"]; ok {
+		t.Errorf("The _id field isn't allowed by GELF")
+	}
+	if ts, ok := doc["timestamp"].(float64); !ok || time.Since(time.Unix(int64(ts), 0)) > time.Minute {
+		t.Errorf("Unexpected timestamp: %v", doc["timestamp"])
+	}
+}
+
+func TestGelfEntryFields(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start udp listener: %s", err.Error())
+	}
+	defer c.Close()
+
+	opts := GelfOptions{Fields: map[string]interface{}{"env": "test"}}
+	logger := createGelfLogger(c.LocalAddr().String(), opts, t)
+	defer logger.Close()
+
+	logger.With(
+		Field{"request", "abc123"},
+		Field{"attempt", 2},
+		Field{"cached", true},
+		Field{"env", "staging"},
+		Field{"id", "skipped"},
+		Field{"not allowed", "skipped"},
+	).Infof("fetched")
+	doc := decodeGelf(readGelfDatagram(c, t), t)
+
+	expected := map[string]interface{}{
+		"_request": "abc123",
+		"_attempt": float64(2),
+		"_cached":  "true",
+		"_env":     "staging",
+	}
+	for k, v := range expected {
+		if doc[k] != v {
+			t.Errorf("Unexpected %s: expected %v, got %v", k, v, doc[k])
+		}
+	}
+	for _, k := range []string{"_id", "_not allowed"} {
+		if _, ok := doc[k]; ok {
+			t.Errorf("The %s field isn't allowed by GELF", k)
+		}
+	}
+}
+
+func TestGelfUdpChunkedGzip(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start udp listener: %s", err.Error())
+	}
+	defer c.Close()
+
+	opts := GelfOptions{Compression: GelfGzip, ChunkSize: 100}
+	logger := createGelfLogger(c.LocalAddr().String(), opts, t)
+	defer logger.Close()
+
+	long := strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyz", 40)
+	logger.Infof("%s", long)
+
+	zr, err := gzip.NewReader(bytes.NewReader(readGelfDatagram(c, t)))
+	if err != nil {
+		t.Fatalf("Message isn't gzipped: %s", err.Error())
+	}
+	data, _ := ioutil.ReadAll(zr)
+	if doc := decodeGelf(data, t); doc["short_message"] != long {
+		t.Errorf("Chunked message didn't survive reassembly: %v", doc["short_message"])
+	}
+}
+
+func TestGelfTcpNullFraming(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start tcp listener: %s", err.Error())
+	}
+	defer l.Close()
+
+	proc, err := NewGelfTcpProcessorAt(l.Addr().String(), LOG_INFO, GelfOptions{})
+	if err != nil {
+		t.Fatalf("Couldn't create GELF processor: %s", err.Error())
+	}
+	logger := NewLogger("")
+	logger.AddProcessor("gelf", proc)
+	defer logger.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Couldn't accept connection: %s", err.Error())
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	logger.Infof("one")
+	logger.Debugf("filtered")
+	logger.Errorf("two")
+
+	r := bufio.NewReader(conn)
+	for _, expected := range []string{"one", "two"} {
+		frame, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatalf("Couldn't read null terminated frame: %s", err.Error())
+		}
+		doc := decodeGelf(frame[:len(frame)-1], t)
+		if doc["short_message"] != expected {
+			t.Errorf("Expected %q, got %v", expected, doc["short_message"])
+		}
+	}
+}
+
+func TestGelfTcpWriteDeadline(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start tcp listener: %s", err.Error())
+	}
+
+	// Accept connections but never read from them, like a stuck input.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	opts := TcpOptions{WriteTimeout: 20 * time.Millisecond}
+	opts.MinBackoff = time.Hour
+	gw, err := DialGelfTcp(l.Addr().String(), opts)
+	if err != nil {
+		t.Fatalf("Couldn't dial: %s", err.Error())
+	}
+	defer gw.Close()
+	defer l.Close() // Closes the accepted connections, unblocking any write.
+
+	msg := bytes.Repeat([]byte("x"), 1<<20)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 32; i++ {
+			gw.Write(msg)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Writes blocked on a connection which isn't read")
+	}
+}
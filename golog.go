@@ -13,9 +13,10 @@
 package golog
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -150,15 +151,35 @@ type Logger struct {
 	// prefix used to prepend to logs if no other prefix is supplied.
 	prefix     string
 	processors map[string]LogProcessor
-	mu         sync.RWMutex // Read/Write Lock used to protect the prefix.
+	fields     []Field       // Attached to every entry, see With.
+	stacks     *atomic.Int32 // Priority errors get stacks captured at, see CaptureStacks.
+	mu         sync.RWMutex  // Read/Write Lock used to protect the prefix.
 }
 
-// Storage object used to pass the log data over to the Processor.
+// Storage object used to pass the log data over to the Processor.  Entries
+// are pooled (see LogProcessor), but each one gets its own copy of the
+// message in Msg so that processors can keep it.  That copy is the one
+// allocation left in a logging call which reaches a processor.
 type LogEntry struct {
 	Prefix   string    // Prefix to prepend to the log message.
 	Priority Priority  // Priority of the log message.
 	Msg      string    // The actual message payload
+	Format   string    // Format string Msg was built from.
 	Created  time.Time // Time this message was created.
+	Fields   []Field   // Structured data, only used by some processors.
+	File     string    // Source file of the logging call, see CallerProcessor.
+	Line     int       // Line of the logging call, see CallerProcessor.
+}
+
+// Entries are reused once every processor is done with them.
+var entryPool = sync.Pool{New: func() interface{} { return new(LogEntry) }}
+
+// A piece of structured data attached to log entries.  Processors with a
+// structured output (JSON, GELF, Loki, ...) write fields out as their own
+// keys, the others ignore them, apart from errors (see Err).
+type Field struct {
+	Key   string
+	Value interface{}
 }
 
 func (dl *Logger) SetPrefix(newPrefix string) {
@@ -234,69 +255,245 @@ func (dl *Logger) Close() {
 	}
 }
 
+// Create a Logger attaching the given fields, on top of this Logger's own,
+// to every entry it logs.  The new Logger starts with this Logger's prefix
+// and shares its processors, so adding or closing processors on either one
+// affects both.
+func (dl *Logger) With(fields ...Field) *Logger {
+	dl.mu.RLock()
+	prefix := dl.prefix
+	dl.mu.RUnlock()
+
+	all := make([]Field, 0, len(dl.fields)+len(fields))
+	all = append(all, dl.fields...)
+	all = append(all, fields...)
+	return &Logger{prefix: prefix, processors: dl.processors, fields: all, stacks: dl.stacks}
+}
+
 // Begin Logging interface.  The following methods are used for logging
 // messages to whatever processors this logger is associated with.
 //
 func (dl *Logger) Plogf(priority Priority, prefix string, format string, args ...interface{}) {
-	message := format
+	dl.plogf(1, priority, prefix, format, args...)
+}
+
+// How the arguments of a Sprint style logging call make up its message.
+type logMode int
+
+const (
+	modePrint   logMode = iota // fmt.Sprint
+	modePrintln                // fmt.Sprintln
+)
+
+// Skip is the number of exported logging methods between the caller and
+// plogf, so that we can find where the logging call was made.
+//
+// The format and args are handed to fmt.Appendf as they are, which is what
+// lets go vet check the calls to every printf style method.
+func (dl *Logger) plogf(skip int, priority Priority, prefix string, format string, args ...interface{}) {
+	priority = BoundPriority(priority)
+	if priority > dl.GetMaxPriority() {
+		// Nobody wants it, don't bother formatting.
+		return
+	}
+
+	buf := getBuffer()
 	if len(args) > 0 {
-		message = fmt.Sprintf(format, args...)
+		*buf = fmt.Appendf(*buf, format, args...)
+	} else {
+		*buf = append(*buf, format...)
 	}
+	dl.output(skip+1, priority, prefix, format, buf)
+}
 
-	if len(message) == 0 || message[len(message)-1] != '\n' {
-		message = message + "\n"
+// Build the entry of a logging call from its message, which output takes
+// ownership of, and hand it to the processors.  Callers only build the
+// message, and evaluate Lazy values, when some processor accepts the
+// priority.
+func (dl *Logger) output(skip int, priority Priority, prefix string, format string, buf *[]byte) {
+	if len(*buf) == 0 || (*buf)[len(*buf)-1] != '\n' {
+		*buf = append(*buf, '\n')
 	}
 
-	entry := &LogEntry{
-		Prefix:   prefix,
-		Priority: BoundPriority(priority),
-		Msg:      message,
-		Created:  time.Now(),
+	entry := entryPool.Get().(*LogEntry)
+	entry.Prefix = prefix
+	entry.Priority = priority
+	entry.Msg = string(*buf) // Copied, since Msg outlives buf (see LogEntry).
+	entry.Format = format
+	entry.Created = time.Now()
+	entry.Fields = dl.fields
+	if len(entry.Fields) > 0 && priority <= Priority(dl.stacks.Load()) {
+		entry.Fields = withStacks(entry.Fields)
+	}
+	putBuffer(buf)
+
+	for _, p := range dl.processors {
+		if cp, ok := p.(CallerProcessor); ok && cp.WantsCaller() {
+			_, entry.File, entry.Line, _ = runtime.Caller(skip + 1)
+			break
+		}
 	}
 
 	for _, p := range dl.processors {
 		p.Process(entry)
 	}
+
+	*entry = LogEntry{}
+	entryPool.Put(entry)
 }
 
 func (dl *Logger) Logf(p Priority, format string, args ...interface{}) {
+	dl.logf(1, p, format, args...)
+}
+
+func (dl *Logger) logf(skip int, p Priority, format string, args ...interface{}) {
 	dl.mu.RLock()
 	prefix := dl.prefix
 	dl.mu.RUnlock()
 
-	dl.Plogf(p, prefix, format, args...)
+	dl.plogf(skip+1, p, prefix, format, args...)
 }
 
 func (dl *Logger) Debugf(format string, args ...interface{}) {
-	dl.Logf(LOG_DEBUG, format, args...)
+	dl.logf(1, LOG_DEBUG, format, args...)
 }
 
 func (dl *Logger) Infof(format string, args ...interface{}) {
-	dl.Logf(LOG_INFO, format, args...)
+	dl.logf(1, LOG_INFO, format, args...)
 }
 
 func (dl *Logger) Noticef(format string, args ...interface{}) {
-	dl.Logf(LOG_NOTICE, format, args...)
+	dl.logf(1, LOG_NOTICE, format, args...)
 }
 
 func (dl *Logger) Warningf(format string, args ...interface{}) {
-	dl.Logf(LOG_WARNING, format, args...)
+	dl.logf(1, LOG_WARNING, format, args...)
 }
 
 func (dl *Logger) Errorf(format string, args ...interface{}) {
-	dl.Logf(LOG_ERR, format, args...)
+	dl.logf(1, LOG_ERR, format, args...)
 }
 
 func (dl *Logger) Criticalf(format string, args ...interface{}) {
-	dl.Logf(LOG_CRIT, format, args...)
+	dl.logf(1, LOG_CRIT, format, args...)
 }
 
 func (dl *Logger) Alertf(format string, args ...interface{}) {
-	dl.Logf(LOG_ALERT, format, args...)
+	dl.logf(1, LOG_ALERT, format, args...)
 }
 
 func (dl *Logger) Emergencyf(format string, args ...interface{}) {
-	dl.Logf(LOG_EMERG, format, args...)
+	dl.logf(1, LOG_EMERG, format, args...)
+}
+
+// The following methods build their message with fmt.Sprint, or
+// fmt.Sprintln for the ln variants, rather than a format string.
+//
+func (dl *Logger) Log(p Priority, args ...interface{}) {
+	dl.log(1, modePrint, p, args)
+}
+
+func (dl *Logger) Logln(p Priority, args ...interface{}) {
+	dl.log(1, modePrintln, p, args)
+}
+
+func (dl *Logger) log(skip int, mode logMode, p Priority, args []interface{}) {
+	p = BoundPriority(p)
+	if p > dl.GetMaxPriority() {
+		return
+	}
+
+	dl.mu.RLock()
+	prefix := dl.prefix
+	dl.mu.RUnlock()
+
+	buf := getBuffer()
+	if mode == modePrintln {
+		*buf = fmt.Appendln(*buf, args...)
+	} else {
+		*buf = fmt.Append(*buf, args...)
+	}
+	dl.output(skip+1, p, prefix, "", buf)
+}
+
+func (dl *Logger) Debug(args ...interface{}) {
+	dl.log(1, modePrint, LOG_DEBUG, args)
+}
+
+func (dl *Logger) Debugln(args ...interface{}) {
+	dl.log(1, modePrintln, LOG_DEBUG, args)
+}
+
+func (dl *Logger) Info(args ...interface{}) {
+	dl.log(1, modePrint, LOG_INFO, args)
+}
+
+func (dl *Logger) Infoln(args ...interface{}) {
+	dl.log(1, modePrintln, LOG_INFO, args)
+}
+
+func (dl *Logger) Notice(args ...interface{}) {
+	dl.log(1, modePrint, LOG_NOTICE, args)
+}
+
+func (dl *Logger) Noticeln(args ...interface{}) {
+	dl.log(1, modePrintln, LOG_NOTICE, args)
+}
+
+func (dl *Logger) Warning(args ...interface{}) {
+	dl.log(1, modePrint, LOG_WARNING, args)
+}
+
+func (dl *Logger) Warningln(args ...interface{}) {
+	dl.log(1, modePrintln, LOG_WARNING, args)
+}
+
+func (dl *Logger) Error(args ...interface{}) {
+	dl.log(1, modePrint, LOG_ERR, args)
+}
+
+func (dl *Logger) Errorln(args ...interface{}) {
+	dl.log(1, modePrintln, LOG_ERR, args)
+}
+
+func (dl *Logger) Critical(args ...interface{}) {
+	dl.log(1, modePrint, LOG_CRIT, args)
+}
+
+func (dl *Logger) Criticalln(args ...interface{}) {
+	dl.log(1, modePrintln, LOG_CRIT, args)
+}
+
+func (dl *Logger) Alert(args ...interface{}) {
+	dl.log(1, modePrint, LOG_ALERT, args)
+}
+
+func (dl *Logger) Alertln(args ...interface{}) {
+	dl.log(1, modePrintln, LOG_ALERT, args)
+}
+
+func (dl *Logger) Emergency(args ...interface{}) {
+	dl.log(1, modePrint, LOG_EMERG, args)
+}
+
+func (dl *Logger) Emergencyln(args ...interface{}) {
+	dl.log(1, modePrintln, LOG_EMERG, args)
+}
+
+// A value computed only when the entry it's logged in is actually written
+// by some processor, for messages costly to build:
+//
+//	logger.Debug("state: ", golog.Lazy(func() string { return dump(state) }))
+//
+// It can be used as an argument of any logging method, or as a Field value.
+type Lazy func() string
+
+func (l Lazy) String() string {
+	return l()
+}
+
+func (l Lazy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l())
 }
 
 // Create a new empty Logger with the given prefix.
@@ -305,7 +502,9 @@ func (dl *Logger) Emergencyf(format string, args ...interface{}) {
 // parameter will be used instead.
 //
 func NewLogger(prefix string) *Logger {
-	return &Logger{prefix: prefix, processors: map[string]LogProcessor{}}
+	stacks := new(atomic.Int32)
+	stacks.Store(int32(log_DISABLE))
+	return &Logger{prefix: prefix, processors: map[string]LogProcessor{}, stacks: stacks}
 }
 
 // ****************************************************************************
@@ -324,7 +523,7 @@ func init() {
 	logchan = make(chan *LogMsg, logQueueSize)
 	go func() {
 		for entry := range logchan {
-			io.WriteString(entry.w, entry.msg)
+			entry.write()
 			shouldDie := atomic.LoadInt32(die)
 			if shouldDie > 0 {
 				break
@@ -333,14 +532,57 @@ func init() {
 	}()
 }
 
+// Longest FlushLogs waits for the log channel to drain.
+const flushTimeout = 5 * time.Second
+
+// Written to by the log go routine once it got to it, see FlushLogs.
+type flushMarker chan struct{}
+
+func (fm flushMarker) Write([]byte) (int, error) {
+	close(fm)
+	return 0, nil
+}
+
+// Wait until every message sent before the call was written out, giving up
+// after a few seconds if a writer is stuck.  Unlike FlushLogsAndDie, logging
+// carries on as usual afterwards.
+func FlushLogs() {
+	if atomic.LoadInt32(die) > 0 {
+		return
+	}
+	done := make(flushMarker)
+	lm := msgPool.Get().(*LogMsg)
+	lm.w, lm.p = done, log_DISABLE
+
+	timeout := time.NewTimer(flushTimeout)
+	defer timeout.Stop()
+	select {
+	case logchan <- lm:
+	case <-timeout.C:
+		return
+	}
+	select {
+	case <-done:
+	case <-timeout.C:
+	}
+}
+
+// Write out every message still waiting in the log channel, along with
+// whatever BufferedWriters hold, and stop the go routine servicing it.  If
+// the process is crashing (see DumpOnPanic and DumpOnSignals), the crash
+// dumps are written first.
 func FlushLogsAndDie() {
+	if atomic.LoadInt32(crashing) > 0 {
+		DumpCrashLogs()
+	}
 	atomic.AddInt32(die, 1)
 	for i := 0; i < logQueueSize; i++ {
 		select {
 		case entry := <-logchan:
-			io.WriteString(entry.w, entry.msg)
+			entry.write()
 		default:
 			break
 		}
 	}
+	syncBufferedWriters()
 }
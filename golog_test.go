@@ -0,0 +1,93 @@
+package golog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestPrintStyleMethods(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	logger := NewLogger("print: ")
+	logger.AddProcessor("ring", ring)
+
+	logger.Info("GET /search?q=100%25&page=", 2)
+	logger.Warningln("took", 12, "ms")
+	logger.Errorf("%d%% done", 50)
+	logger.Log(LOG_NOTICE, "already ends with a newline\n")
+
+	expected := []string{
+		"GET /search?q=100%25&page=2\n",
+		"took 12 ms\n",
+		"50% done\n",
+		"already ends with a newline\n",
+	}
+	msgs := ringMsgs(ring.Snapshot())
+	if len(msgs) != len(expected) {
+		t.Fatalf("Expected %d entries, got %q", len(expected), msgs)
+	}
+	for i, msg := range expected {
+		if msgs[i] != msg {
+			t.Errorf("Expected %q, got %q", msg, msgs[i])
+		}
+	}
+}
+
+func TestLazyOnlyEvaluatedWhenWanted(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_INFO)
+	logger := NewLogger("lazy: ")
+	logger.AddProcessor("ring", ring)
+
+	calls := 0
+	expensive := Lazy(func() string {
+		calls++
+		return "expensive"
+	})
+	logger.Debug("state: ", expensive)
+	if calls != 0 {
+		t.Errorf("Lazy value evaluated for a filtered out entry")
+	}
+	logger.Infof("state: %s", expensive)
+	if calls != 1 {
+		t.Errorf("Expected the lazy value to be evaluated once, got %d", calls)
+	}
+	if msgs := ringMsgs(ring.Snapshot()); len(msgs) != 1 || msgs[0] != "state: expensive\n" {
+		t.Errorf("Unexpected entries %q", msgs)
+	}
+
+	if data := appendJSONValue(nil, expensive); string(data) != `"expensive"` {
+		t.Errorf("Unexpected JSON for a lazy field %s", data)
+	}
+}
+
+// Every printf style method has to hand its format and args over to fmt as
+// they are, or go vet stops checking their calls.  The probe package makes
+// one bad call to each of them.
+func TestVetPrintfWrappers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping go vet in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("Couldn't find the go tool")
+	}
+	probe, err := ioutil.ReadFile("testdata/vetprintf/vetprintf.go")
+	if err != nil {
+		t.Fatalf("Couldn't read the probe: %s", err.Error())
+	}
+
+	out, err := exec.Command(goTool, "vet", "./testdata/vetprintf").CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected go vet to report the probe's calls")
+	}
+	for i, line := range strings.Split(string(probe), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "l.") {
+			continue
+		}
+		if !strings.Contains(string(out), fmt.Sprintf("vetprintf.go:%d:", i+1)) {
+			t.Errorf("go vet didn't report %s\n%s", strings.TrimSpace(line), out)
+		}
+	}
+}
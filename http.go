@@ -0,0 +1,399 @@
+// Log Processor for shipping batches of entries to an HTTP endpoint.  Most
+// log collectors (Loki, Elasticsearch, Splunk HEC, ...) accept batches over
+// HTTP, and only differ in how a batch is encoded, see HttpOptions.Encode.
+//
+package golog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHttpBatchEntries = 500
+	defaultHttpBatchBytes   = 1 << 20
+	defaultHttpFlush        = time.Second
+	defaultHttpQueueSize    = 16
+	defaultHttpRetries      = 5
+	defaultHttpTimeout      = 10 * time.Second
+	defaultHttpContentType  = "application/x-ndjson"
+)
+
+// Options for the HttpWriter.  The zero value is usable.
+type HttpOptions struct {
+	Method      string      // Defaults to POST.
+	Header      http.Header // Extra headers, such as Authorization.
+	ContentType string      // Defaults to application/x-ndjson.
+	Gzip        bool        // Compress request bodies.
+
+	// A batch is sent as soon as it holds MaxBatchEntries entries or
+	// MaxBatchBytes bytes, and at most FlushInterval after its first entry.
+	// They default to 500 entries, 1MB and 1s.
+	MaxBatchEntries int
+	MaxBatchBytes   int
+	FlushInterval   time.Duration
+
+	// How many batches may wait to be sent, defaults to 16.  Batches are
+	// dropped when the queue is full, logging never waits on the network.
+	QueueSize int
+
+	// Failed requests (network errors, 429 and 5xx responses) are retried
+	// up to MaxRetries times, defaults to 5, negative for no retries.  A
+	// Retry-After header takes precedence over the backoff.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	Client  *http.Client  // Defaults to a client with Timeout.
+	Timeout time.Duration // Per request, defaults to 10s.
+
+	// Builds the request body out of a batch of entries.  By default the
+	// entries are simply concatenated.
+	Encode func(entries [][]byte) ([]byte, error)
+
+	// Inspects successful (2xx) responses, for endpoints which may accept
+	// only part of a batch.  It returns the entries worth retrying, and how
+	// many were refused for good.  The body is closed by the caller.
+	Check func(resp *http.Response, entries [][]byte) (retry [][]byte, rejected int)
+}
+
+// Counters kept by the HttpWriter.
+type HttpStats struct {
+	Requests uint64 // Requests made, including retries.
+	Batches  uint64 // Batches delivered.
+	Entries  uint64 // Entries delivered.
+	Retries  uint64 // Requests retried.
+	Dropped  uint64 // Entries dropped because the queue was full.
+	Failed   uint64 // Entries dropped after the endpoint refused them.
+}
+
+// Outcome of a single request.
+type httpResult int
+
+const (
+	httpDelivered httpResult = iota
+	httpRetry
+	httpRejected
+)
+
+// ****************************************************************************
+// The HttpWriter collects the messages written to it into batches and
+// sends them from its own go routine, so a slow endpoint never holds up the
+// log channel.
+//
+type HttpWriter struct {
+	url        string
+	opts       HttpOptions
+	mu         sync.Mutex
+	batch      [][]byte
+	batchBytes int
+	flusher    *time.Timer
+	queue      chan [][]byte
+	closing    chan bool
+	sender     sync.WaitGroup
+	closed     bool
+	stats      HttpStats // Only accessed atomically.
+}
+
+func (hw *HttpWriter) Write(data []byte) (n int, err error) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+
+	if hw.closed {
+		return 0, errWriterClosed
+	}
+
+	hw.batch = append(hw.batch, append([]byte(nil), data...))
+	hw.batchBytes += len(data)
+	if len(hw.batch) >= hw.opts.MaxBatchEntries || hw.batchBytes >= hw.opts.MaxBatchBytes {
+		hw.enqueue()
+	} else if hw.flusher == nil {
+		hw.flusher = time.AfterFunc(hw.opts.FlushInterval, hw.flushLater)
+	}
+	return len(data), nil
+}
+
+// Send the pending batch, then wait for every queued batch to be sent.
+// Retries still pending are abandoned, and the batches left get a single
+// attempt.
+func (hw *HttpWriter) Close() error {
+	hw.mu.Lock()
+	if hw.closed {
+		hw.mu.Unlock()
+		return nil
+	}
+	hw.closed = true
+	if hw.flusher != nil {
+		hw.flusher.Stop()
+		hw.flusher = nil
+	}
+	batch := hw.batch
+	hw.batch, hw.batchBytes = nil, 0
+	close(hw.closing)
+	hw.mu.Unlock()
+
+	// Nothing else queues batches once closed is set, and the sender stops
+	// retrying, so this doesn't wait long.
+	if len(batch) > 0 {
+		hw.queue <- batch
+	}
+	close(hw.queue)
+	hw.sender.Wait()
+	return nil
+}
+
+// Get a snapshot of the writer's counters.
+func (hw *HttpWriter) Stats() HttpStats {
+	return HttpStats{
+		Requests: atomic.LoadUint64(&hw.stats.Requests),
+		Batches:  atomic.LoadUint64(&hw.stats.Batches),
+		Entries:  atomic.LoadUint64(&hw.stats.Entries),
+		Retries:  atomic.LoadUint64(&hw.stats.Retries),
+		Dropped:  atomic.LoadUint64(&hw.stats.Dropped),
+		Failed:   atomic.LoadUint64(&hw.stats.Failed),
+	}
+}
+
+func (hw *HttpWriter) flushLater() {
+	hw.mu.Lock()
+	hw.flusher = nil
+	if !hw.closed {
+		hw.enqueue()
+	}
+	hw.mu.Unlock()
+}
+
+// Hand the pending batch over to the sender.  Must be called with hw.mu
+// held.  The batch is dropped if the queue is full.
+func (hw *HttpWriter) enqueue() {
+	if hw.flusher != nil {
+		hw.flusher.Stop()
+		hw.flusher = nil
+	}
+	if len(hw.batch) == 0 {
+		return
+	}
+
+	select {
+	case hw.queue <- hw.batch:
+	default:
+		atomic.AddUint64(&hw.stats.Dropped, uint64(len(hw.batch)))
+	}
+	hw.batch, hw.batchBytes = nil, 0
+}
+
+func (hw *HttpWriter) send() {
+	defer hw.sender.Done()
+	for batch := range hw.queue {
+		failed := hw.post(batch)
+		if failed < len(batch) {
+			atomic.AddUint64(&hw.stats.Batches, 1)
+			atomic.AddUint64(&hw.stats.Entries, uint64(len(batch)-failed))
+		}
+		atomic.AddUint64(&hw.stats.Failed, uint64(failed))
+	}
+}
+
+// Deliver one batch, retrying as configured.  Returns how many of its
+// entries couldn't be delivered.
+func (hw *HttpWriter) post(batch [][]byte) (failed int) {
+	backoff := Backoff{Min: hw.opts.MinBackoff, Max: hw.opts.MaxBackoff}
+	for attempt := 0; ; attempt++ {
+		body, err := hw.opts.Encode(batch)
+		if err == nil && hw.opts.Gzip {
+			body, err = gzipBytes(body)
+		}
+		if err != nil {
+			return failed + len(batch)
+		}
+
+		result, wait, retry, rejected := hw.request(body, batch)
+		failed += rejected
+		switch result {
+		case httpDelivered:
+			return failed
+		case httpRejected:
+			return failed + len(batch)
+		}
+
+		batch = retry
+		if attempt >= hw.opts.MaxRetries {
+			return failed + len(batch)
+		}
+		if wait <= 0 {
+			wait = backoff.Next()
+		}
+
+		select {
+		case <-time.After(wait):
+			atomic.AddUint64(&hw.stats.Retries, 1)
+		case <-hw.closing:
+			return failed + len(batch)
+		}
+	}
+}
+
+// Make a single request for entries.  When it should be retried, wait is
+// how long the endpoint asked us to wait, if it did, and retry holds the
+// entries to send again.  Rejected counts entries refused for good by a
+// partially successful request.
+func (hw *HttpWriter) request(body []byte, entries [][]byte) (result httpResult, wait time.Duration, retry [][]byte, rejected int) {
+	req, err := http.NewRequest(hw.opts.Method, hw.url, bytes.NewReader(body))
+	if err != nil {
+		return httpRejected, 0, nil, 0
+	}
+	for name, values := range hw.opts.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", hw.opts.ContentType)
+	if hw.opts.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	atomic.AddUint64(&hw.stats.Requests, 1)
+	resp, err := hw.opts.Client.Do(req)
+	if err != nil {
+		return httpRetry, 0, entries, 0
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		if hw.opts.Check != nil {
+			retry, rejected = hw.opts.Check(resp, entries)
+			if len(retry) > 0 {
+				return httpRetry, 0, retry, rejected
+			}
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		return httpDelivered, 0, nil, rejected
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		io.Copy(ioutil.Discard, resp.Body)
+		return httpRetry, retryAfter(resp), entries, 0
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return httpRejected, 0, nil, 0
+}
+
+// Parse the delay out of a Retry-After header given in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return 0
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func concatEntries(entries [][]byte) ([]byte, error) {
+	return bytes.Join(entries, nil), nil
+}
+
+func NewHttpWriter(endpoint string, opts HttpOptions) (*HttpWriter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("golog: HTTP endpoint must be an http or https URL")
+	}
+
+	if opts.Method == "" {
+		opts.Method = http.MethodPost
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = defaultHttpContentType
+	}
+	if opts.MaxBatchEntries <= 0 {
+		opts.MaxBatchEntries = defaultHttpBatchEntries
+	}
+	if opts.MaxBatchBytes <= 0 {
+		opts.MaxBatchBytes = defaultHttpBatchBytes
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultHttpFlush
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultHttpQueueSize
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = defaultHttpRetries
+	} else if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultHttpTimeout
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: opts.Timeout}
+	}
+	if opts.Encode == nil {
+		opts.Encode = concatEntries
+	}
+
+	hw := &HttpWriter{
+		url:     u.String(),
+		opts:    opts,
+		queue:   make(chan [][]byte, opts.QueueSize),
+		closing: make(chan bool),
+	}
+	hw.sender.Add(1)
+	go hw.send()
+	return hw, nil
+}
+
+// ****************************************************************************
+// The HttpProcessor sends entries as JSON lines (see appendJSONEntry)
+// through an HttpWriter.
+//
+type HttpProcessor struct {
+	*DefaultProcessor
+	writer *HttpWriter
+}
+
+func (hp *HttpProcessor) Process(entry *LogEntry) {
+	if entry.Priority <= hp.GetPriority() {
+		hp.processUnfiltered(entry)
+	}
+}
+
+func (hp *HttpProcessor) processUnfiltered(entry *LogEntry) {
+	config := hp.loadConfig()
+	lm := hp.Dispatcher.message(entry.Priority)
+	lm.msg = appendJSONEntry(lm.msg, entry, config.timeFormat)
+	hp.Dispatcher.dispatch(lm)
+}
+
+// Get the counters of the underlying HttpWriter.
+func (hp *HttpProcessor) Stats() HttpStats {
+	return hp.writer.Stats()
+}
+
+func NewHttpProcessor(endpoint string, p Priority, opts HttpOptions) (LogProcessor, error) {
+	hw, err := NewHttpWriter(endpoint, opts)
+	if err != nil {
+		return nil, err
+	}
+	dsp := NewLogDispatcher(hw)
+	defaultProcessor := NewProcessor(p, dsp, true).(*DefaultProcessor)
+	return &HttpProcessor{DefaultProcessor: defaultProcessor, writer: hw}, nil
+}
@@ -0,0 +1,191 @@
+package golog
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records the bodies posted to it, answering with the given statuses first.
+type fakeIngest struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+	received chan bool
+}
+
+func newFakeIngest(statuses ...int) (*fakeIngest, *httptest.Server) {
+	fi := &fakeIngest{statuses: statuses, received: make(chan bool, 16)}
+	return fi, httptest.NewServer(fi)
+}
+
+func (fi *fakeIngest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if len(fi.statuses) > 0 {
+		status := fi.statuses[0]
+		fi.statuses = fi.statuses[1:]
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	body := r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		body, _ = gzip.NewReader(r.Body)
+	}
+	data, _ := ioutil.ReadAll(body)
+	fi.bodies = append(fi.bodies, string(data))
+	fi.headers = append(fi.headers, r.Header)
+	fi.received <- true
+}
+
+func (fi *fakeIngest) wait(t *testing.T) {
+	select {
+	case <-fi.received:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for a batch")
+	}
+}
+
+func (fi *fakeIngest) body(i int) string {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.bodies[i]
+}
+
+func TestHttpBatchByCount(t *testing.T) {
+	fi, server := newFakeIngest()
+	defer server.Close()
+
+	opts := HttpOptions{MaxBatchEntries: 3, FlushInterval: time.Hour}
+	proc, err := NewHttpProcessor(server.URL, LOG_INFO, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create http processor: %s", err.Error())
+	}
+	logger := NewLogger("")
+	logger.AddProcessor("http", proc)
+	defer logger.Close()
+
+	for _, msg := range []string{"one", "two", "three", "four"} {
+		logger.Infof("%s", msg)
+	}
+	fi.wait(t)
+	if lines := strings.Split(strings.TrimSpace(fi.body(0)), "\n"); len(lines) != 3 {
+		t.Errorf("Expected a batch of 3 lines, got %q", fi.body(0))
+	}
+}
+
+func TestHttpBatchByIntervalWithGzipAndHeaders(t *testing.T) {
+	fi, server := newFakeIngest()
+	defer server.Close()
+
+	opts := HttpOptions{
+		FlushInterval: 20 * time.Millisecond,
+		Gzip:          true,
+		Header:        http.Header{"Authorization": {"Bearer s3cr3t"}},
+	}
+	proc, err := NewHttpProcessor(server.URL, LOG_INFO, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create http processor: %s", err.Error())
+	}
+	logger := NewLogger("http_test: ")
+	logger.AddProcessor("http", proc)
+	defer logger.Close()
+
+	logger.Warningf("hello")
+	fi.wait(t)
+	if !strings.Contains(fi.body(0), `"msg":"hello"`) {
+		t.Errorf("Unexpected body %q", fi.body(0))
+	}
+	fi.mu.Lock()
+	header := fi.headers[0]
+	fi.mu.Unlock()
+	if header.Get("Authorization") != "Bearer s3cr3t" || header.Get("Content-Type") != defaultHttpContentType {
+		t.Errorf("Unexpected headers: %v", header)
+	}
+}
+
+func TestHttpRetries(t *testing.T) {
+	fi, server := newFakeIngest(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer server.Close()
+
+	opts := HttpOptions{FlushInterval: time.Millisecond, MinBackoff: time.Millisecond}
+	hw, err := NewHttpWriter(server.URL, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create http writer: %s", err.Error())
+	}
+
+	hw.Write([]byte("retried\n"))
+	fi.wait(t)
+	hw.Close()
+
+	if fi.body(0) != "retried\n" {
+		t.Errorf("Unexpected body %q", fi.body(0))
+	}
+	stats := hw.Stats()
+	if stats.Requests != 3 || stats.Retries != 2 || stats.Batches != 1 || stats.Entries != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestHttpRejected(t *testing.T) {
+	_, server := newFakeIngest(http.StatusBadRequest)
+	defer server.Close()
+
+	hw, err := NewHttpWriter(server.URL, HttpOptions{})
+	if err != nil {
+		t.Fatalf("Couldn't create http writer: %s", err.Error())
+	}
+	hw.Write([]byte("bad\n"))
+	hw.Close()
+
+	if stats := hw.Stats(); stats.Requests != 1 || stats.Failed != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestHttpCloseDoesntWaitForRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	opts := HttpOptions{MaxBatchEntries: 2, QueueSize: 1}
+	hw, err := NewHttpWriter(server.URL, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create http writer: %s", err.Error())
+	}
+	// The sender waits on the first batch, the second fills the queue, and
+	// the last one is pending.
+	hw.Write([]byte("first\n"))
+	hw.Write([]byte("first\n"))
+	for hw.Stats().Requests == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	hw.Write([]byte("second\n"))
+	hw.Write([]byte("second\n"))
+	hw.Write([]byte("pending\n"))
+
+	closed := make(chan bool)
+	go func() {
+		hw.Close()
+		closed <- true
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Close waited on the endpoint's Retry-After")
+	}
+	if stats := hw.Stats(); stats.Failed != 5 {
+		t.Errorf("Expected all 5 entries to fail, got %+v", stats)
+	}
+}
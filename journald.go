@@ -0,0 +1,154 @@
+// Log Processor for writing to systemd-journald through its native
+// protocol, which keeps fields structured unlike /dev/log.
+//
+package golog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultJournalSocket = "/run/systemd/journal/socket"
+	maxJournalFieldName  = 64
+)
+
+// ****************************************************************************
+// The JournaldWriter sends each message as a single datagram to the
+// journal socket.  Messages too large for a datagram are written to a
+// sealed memfd, or an unlinked file in /dev/shm on kernels without memfds,
+// whose descriptor is passed to journald instead, as sd_journal_sendv does.
+//
+type JournaldWriter struct {
+	conn *net.UnixConn
+}
+
+func (jw *JournaldWriter) Write(data []byte) (n int, err error) {
+	if n, err = jw.conn.Write(data); err != nil && isMsgTooLarge(err) {
+		if err = sendJournalFd(jw.conn, data); err == nil {
+			n = len(data)
+		}
+	}
+	return n, err
+}
+
+func (jw *JournaldWriter) Close() error {
+	return jw.conn.Close()
+}
+
+func DialJournald(path string) (*JournaldWriter, error) {
+	if path == "" {
+		path = defaultJournalSocket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &JournaldWriter{conn: conn}, nil
+}
+
+// ****************************************************************************
+// The JournaldProcessor maps entries onto journal fields: the message goes
+// to MESSAGE, the priority to PRIORITY, the prefix to SYSLOG_IDENTIFIER and
+// the caller to CODE_FILE and CODE_LINE.  Entry fields are added with their
+// keys upper cased, and anything journald doesn't allow in a field name
+// replaced by an underscore.
+//
+type JournaldProcessor struct {
+	*DefaultProcessor
+	identifier string // Used for entries without a prefix.
+}
+
+func (jp *JournaldProcessor) WantsCaller() bool {
+	return true
+}
+
+func (jp *JournaldProcessor) Process(entry *LogEntry) {
+	if entry.Priority <= jp.GetPriority() {
+		jp.processUnfiltered(entry)
+	}
+}
+
+func (jp *JournaldProcessor) processUnfiltered(entry *LogEntry) {
+	jp.Dispatcher.SendBytes(entry.Priority, jp.format(entry))
+}
+
+func (jp *JournaldProcessor) format(entry *LogEntry) []byte {
+	identifier := strings.TrimRight(strings.TrimSpace(entry.Prefix), ":")
+	if identifier == "" {
+		identifier = jp.identifier
+	}
+
+	var buf []byte
+	buf = appendJournalField(buf, "MESSAGE", strings.TrimRight(entry.Msg, "\n"))
+	buf = appendJournalField(buf, "PRIORITY", strconv.Itoa(int(entry.Priority)))
+	buf = appendJournalField(buf, "SYSLOG_IDENTIFIER", identifier)
+	if entry.File != "" {
+		buf = appendJournalField(buf, "CODE_FILE", entry.File)
+		buf = appendJournalField(buf, "CODE_LINE", strconv.Itoa(entry.Line))
+	}
+	for _, field := range entry.Fields {
+		if name := journalFieldName(field.Key); name != "" {
+			buf = appendJournalField(buf, name, fmt.Sprint(field.Value))
+		}
+	}
+	return buf
+}
+
+// Fields are written as NAME=value lines, unless the value holds a newline
+// in which case it's NAME, a newline, its 64 bit little endian length, the
+// value itself and a final newline.
+func appendJournalField(buf []byte, name, value string) []byte {
+	buf = append(buf, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf = append(buf, '=')
+		buf = append(buf, value...)
+		return append(buf, '\n')
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf = append(buf, '\n')
+	buf = append(buf, size[:]...)
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
+
+// Journal field names only hold upper case letters, digits and
+// underscores, can't start with an underscore (those are set by journald
+// itself) or a digit, and are at most 64 characters long.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(strings.TrimLeft(key, "_")))
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 0 && name[0] >= '0' && name[0] <= '9' {
+		name = append([]byte("F_"), name...)
+	}
+	if len(name) > maxJournalFieldName {
+		name = name[:maxJournalFieldName]
+	}
+	return string(name)
+}
+
+func NewJournaldProcessorAt(path string, p Priority) (LogProcessor, error) {
+	jw, err := DialJournald(path)
+	if err != nil {
+		return nil, err
+	}
+	dsp := NewLogDispatcher(jw)
+	defaultProcessor := NewProcessor(p, dsp, true).(*DefaultProcessor)
+	identifier := filepath.Base(os.Args[0])
+	return &JournaldProcessor{DefaultProcessor: defaultProcessor, identifier: identifier}, nil
+}
+
+func NewJournaldProcessor(p Priority) (LogProcessor, error) {
+	return NewJournaldProcessorAt(defaultJournalSocket, p)
+}
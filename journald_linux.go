@@ -0,0 +1,118 @@
+package golog
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// memfd_create isn't in the syscall package for every architecture, so its
+// number is looked up here.  It's zero, and we fall back to /dev/shm, on
+// the others.
+var sysMemfdCreate = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips":     4354,
+	"mipsle":   4354,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}[runtime.GOARCH]
+
+// From linux/memfd.h and linux/fcntl.h.
+const (
+	mfdCloexec       = 0x1
+	mfdAllowSealing  = 0x2
+	fAddSeals        = 1033
+	fSealSeal        = 0x1
+	fSealShrink      = 0x2
+	fSealGrow        = 0x4
+	fSealWrite       = 0x8
+	journalFileSeals = fSealSeal | fSealShrink | fSealGrow | fSealWrite
+)
+
+func isMsgTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// Write data to a sealed memfd, as sd_journal_sendv does, or failing that
+// to an unlinked file in /dev/shm, and pass its descriptor to journald.
+func sendJournalFd(conn *net.UnixConn, data []byte) error {
+	f, err := journalMemfd(data)
+	if err != nil {
+		if f, err = journalShmFile(data); err != nil {
+			return err
+		}
+	}
+	defer f.Close()
+
+	// The net package won't send control messages over a connected
+	// datagram socket, so go through sendmsg ourselves.
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	var serr error
+	err = raw.Write(func(fd uintptr) bool {
+		serr = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return serr != syscall.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// Journald only accepts memfds once they're sealed against any change.
+func journalMemfd(data []byte) (*os.File, error) {
+	if sysMemfdCreate == 0 {
+		return nil, syscall.ENOSYS
+	}
+	name, err := syscall.BytePtrFromString("golog-journal")
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	f := os.NewFile(fd, "golog-journal")
+
+	if _, err = f.Write(data); err == nil {
+		if _, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, journalFileSeals); errno != 0 {
+			err = errno
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Journald reads files which aren't memfds as long as they live in
+// /dev/shm, /tmp or /var/tmp, and /dev/shm keeps it all in memory.
+func journalShmFile(data []byte) (*os.File, error) {
+	f, err := ioutil.TempFile("/dev/shm", "golog-journal")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
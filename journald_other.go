@@ -0,0 +1,16 @@
+//go:build !linux
+
+package golog
+
+import (
+	"errors"
+	"net"
+)
+
+func isMsgTooLarge(err error) bool {
+	return false
+}
+
+func sendJournalFd(conn *net.UnixConn, data []byte) error {
+	return errors.New("golog: passing large journal entries is only supported on linux")
+}
@@ -0,0 +1,135 @@
+//go:build linux
+
+package golog
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func listenJournal(t *testing.T) (*net.UnixConn, string, func()) {
+	dir, err := ioutil.TempDir("", "golog_journal")
+	if err != nil {
+		t.Fatalf("Couldn't create tmp dir: %s", err.Error())
+	}
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Couldn't listen on unixgram: %s", err.Error())
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	return conn, path, func() { conn.Close(); os.RemoveAll(dir) }
+}
+
+// Read a datagram, following a passed file descriptor if there is one.
+func readJournal(conn *net.UnixConn, t *testing.T) []byte {
+	buf := make([]byte, 65536)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("Couldn't read from journal socket: %s", err.Error())
+	}
+	if oobn == 0 {
+		return buf[:n]
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Invalid control message: %v", err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("Invalid unix rights: %v", err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	f.Seek(0, 0)
+	data, _ := ioutil.ReadAll(f)
+	return data
+}
+
+func TestJournaldFields(t *testing.T) {
+	conn, path, cleanup := listenJournal(t)
+	defer cleanup()
+
+	proc, err := NewJournaldProcessorAt(path, LOG_INFO)
+	if err != nil {
+		t.Fatalf("Couldn't create journald processor: %s", err.Error())
+	}
+	logger := NewLogger("myapp: ")
+	logger.AddProcessor("journald", proc)
+	defer logger.Close()
+
+	logger.With(Field{"request-id", "r1"}, Field{"_PID", 1}).Warningf("two\nlines")
+	data := string(readJournal(conn, t))
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], 9)
+	expected := []string{
+		"MESSAGE\n" + string(size[:]) + "two\nlines\n",
+		"PRIORITY=4\n",
+		"SYSLOG_IDENTIFIER=myapp\n",
+		"CODE_FILE=",
+		"journald_test.go\nCODE_LINE=",
+		"REQUEST_ID=r1\n",
+		"PID=1\n",
+	}
+	for _, field := range expected {
+		if !strings.Contains(data, field) {
+			t.Errorf("Expected %q in journal entry %q", field, data)
+		}
+	}
+}
+
+func TestJournaldLargeEntry(t *testing.T) {
+	conn, path, cleanup := listenJournal(t)
+	defer cleanup()
+
+	jw, err := DialJournald(path)
+	if err != nil {
+		t.Fatalf("Couldn't connect to journal socket: %s", err.Error())
+	}
+	defer jw.Close()
+
+	large := appendJournalField(nil, "MESSAGE", strings.Repeat("x", 4<<20))
+	if _, err := jw.Write(large); err != nil {
+		t.Fatalf("Couldn't write large entry: %s", err.Error())
+	}
+	if data := readJournal(conn, t); string(data) != string(large) {
+		t.Errorf("Large entry didn't make it through, got %d bytes", len(data))
+	}
+}
+
+func TestJournalMemfdIsSealed(t *testing.T) {
+	f, err := journalMemfd([]byte("sealed\n"))
+	if err == syscall.ENOSYS || err == syscall.EPERM {
+		t.Skipf("No memfd here: %s", err.Error())
+	} else if err != nil {
+		t.Fatalf("Couldn't create memfd: %s", err.Error())
+	}
+	defer f.Close()
+
+	const fGetSeals = 1034
+	seals, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), fGetSeals, 0)
+	if errno != 0 {
+		t.Fatalf("Couldn't get the seals: %s", errno.Error())
+	}
+	if seals != journalFileSeals {
+		t.Errorf("Expected seals %#x, got %#x", journalFileSeals, seals)
+	}
+	if _, err := f.Write([]byte("more")); err == nil {
+		t.Errorf("Expected writing to the sealed memfd to fail")
+	}
+	f.Seek(0, 0)
+	if data, _ := ioutil.ReadAll(f); string(data) != "sealed\n" {
+		t.Errorf("Unexpected memfd contents %q", data)
+	}
+}
@@ -0,0 +1,55 @@
+package golog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Time format used for JSON output unless the processor was given one.
+const defaultJSONTimeFormat = time.RFC3339Nano
+
+// Append entry to buf as a single line JSON object:
+//
+//	{"time":"...","level":"INFO","prefix":"...","msg":"...","field":...}
+//
+// followed by a newline, as expected by log shippers reading newline
+// delimited JSON.  Fields are written after the standard keys.
+func appendJSONEntry(buf []byte, entry *LogEntry, timeFormat string) []byte {
+	if timeFormat == "" {
+		timeFormat = defaultJSONTimeFormat
+	}
+	buf = append(buf, `{"time":`...)
+	buf = appendJSONString(buf, entry.Created.Format(timeFormat))
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONString(buf, entry.Priority.String())
+	if entry.Prefix != "" {
+		buf = append(buf, `,"prefix":`...)
+		buf = appendJSONString(buf, entry.Prefix)
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, strings.TrimRight(entry.Msg, "\n"))
+	for _, field := range entry.Fields {
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, field.Key)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, field.Value)
+	}
+	return append(buf, "}\n"...)
+}
+
+// Values which can't be marshaled are written as their fmt string.
+func appendJSONValue(buf []byte, value interface{}) []byte {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return appendJSONString(buf, fmt.Sprint(value))
+	}
+	return append(buf, data...)
+}
+
+func appendJSONString(buf []byte, s string) []byte {
+	// Marshaling a string can't fail.
+	data, _ := json.Marshal(s)
+	return append(buf, data...)
+}
@@ -0,0 +1,233 @@
+// Log Processor for pushing entries to Grafana Loki through its JSON push
+// API, built on top of the HttpWriter.
+//
+package golog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	lokiPushPath           = "/loki/api/v1/push"
+	defaultLokiLevelLabel  = "level"
+	defaultLokiLabelValues = 50
+)
+
+// Loki rejects whole pushes with a label name not matching this.
+var lokiLabelRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Options for the LokiProcessor.  The zero value is usable.
+type LokiOptions struct {
+	HttpOptions
+
+	// Labels given to every stream, such as {"job": "api"}.  Label names,
+	// here and in LevelLabel and FieldLabels, can only be made of letters,
+	// digits and underscores, and can't start with a digit.
+	Labels map[string]string
+
+	// Label holding the entry's priority, in lower case.  Defaults to
+	// "level", set it to "-" to leave the priority out of the labels.
+	LevelLabel string
+
+	// Keys of entry fields which are promoted to labels.  Every distinct
+	// label set is a new stream in Loki, so only MaxLabelValues distinct
+	// values (50 by default) are promoted per key; after that, the field
+	// stays in the log line like any other.
+	FieldLabels    []string
+	MaxLabelValues int
+
+	// Sent as X-Scope-OrgID for multi-tenant Loki installations.
+	TenantID string
+}
+
+// ****************************************************************************
+// The LokiProcessor turns every entry into a line of a Loki stream.  The
+// stream is picked by the entry's labels, and fields which aren't labels are
+// appended to the line in logfmt so Loki's logfmt parser can pick them up.
+//
+// Entries go through the dispatcher as "labels\x00timestamp\x00line"
+// records, which encodeLokiPush groups into streams once per batch.
+//
+type LokiProcessor struct {
+	*DefaultProcessor
+	writer      *HttpWriter
+	labels      map[string]string
+	levelLabel  string
+	fieldLabels map[string]bool
+	maxValues   int
+
+	mu     sync.Mutex
+	values map[string]map[string]bool // Label values promoted so far, by key.
+}
+
+func (lp *LokiProcessor) Process(entry *LogEntry) {
+	if entry.Priority <= lp.GetPriority() {
+		lp.processUnfiltered(entry)
+	}
+}
+
+func (lp *LokiProcessor) processUnfiltered(entry *LogEntry) {
+	lp.Dispatcher.SendBytes(entry.Priority, lp.record(entry))
+}
+
+// Get the counters of the underlying HttpWriter.
+func (lp *LokiProcessor) Stats() HttpStats {
+	return lp.writer.Stats()
+}
+
+func (lp *LokiProcessor) record(entry *LogEntry) []byte {
+	labels := make(map[string]string, len(lp.labels)+2)
+	for name, value := range lp.labels {
+		labels[name] = value
+	}
+	if lp.levelLabel != "" {
+		labels[lp.levelLabel] = strings.ToLower(entry.Priority.String())
+	}
+
+	line := strings.TrimRight(entry.Prefix+entry.Msg, "\n")
+	for _, field := range entry.Fields {
+		value := fmt.Sprint(field.Value)
+		if lp.fieldLabels[field.Key] && lp.promote(field.Key, value) {
+			labels[field.Key] = value
+			continue
+		}
+		line += " " + field.Key + "=" + logfmtValue(value)
+	}
+
+	// Map keys are sorted when marshaled, so equal label sets always give
+	// the same string.
+	stream, _ := json.Marshal(labels)
+	ts := strconv.FormatInt(entry.Created.UnixNano(), 10)
+
+	var rec bytes.Buffer
+	rec.Write(stream)
+	rec.WriteByte(0)
+	rec.WriteString(ts)
+	rec.WriteByte(0)
+	rec.WriteString(line)
+	return rec.Bytes()
+}
+
+// Check whether value can become a label without going over the
+// cardinality limit of its key.
+func (lp *LokiProcessor) promote(key, value string) bool {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	seen := lp.values[key]
+	if seen == nil {
+		seen = map[string]bool{}
+		lp.values[key] = seen
+	}
+	if seen[value] {
+		return true
+	}
+	if len(seen) >= lp.maxValues {
+		return false
+	}
+	seen[value] = true
+	return true
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \"=\\\t\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+type lokiStream struct {
+	Stream json.RawMessage `json:"stream"`
+	Values [][2]string     `json:"values"`
+}
+
+type lokiPush struct {
+	Streams []*lokiStream `json:"streams"`
+}
+
+// Group a batch of records into the streams of a push request.
+func encodeLokiPush(records [][]byte) ([]byte, error) {
+	push := lokiPush{}
+	streams := map[string]*lokiStream{}
+	for _, rec := range records {
+		parts := bytes.SplitN(rec, []byte{0}, 3)
+		if len(parts) != 3 {
+			continue
+		}
+		stream := streams[string(parts[0])]
+		if stream == nil {
+			stream = &lokiStream{Stream: json.RawMessage(parts[0])}
+			streams[string(parts[0])] = stream
+			push.Streams = append(push.Streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{string(parts[1]), string(parts[2])})
+	}
+	return json.Marshal(push)
+}
+
+// Create a LokiProcessor pushing to the Loki server at baseURL, such as
+// "http://loki:3100".
+func NewLokiProcessor(baseURL string, p Priority, opts LokiOptions) (LogProcessor, error) {
+	levelLabel := opts.LevelLabel
+	if levelLabel == "" {
+		levelLabel = defaultLokiLevelLabel
+	} else if levelLabel == "-" {
+		levelLabel = ""
+	}
+	names := append([]string(nil), opts.FieldLabels...)
+	for name := range opts.Labels {
+		names = append(names, name)
+	}
+	if levelLabel != "" {
+		names = append(names, levelLabel)
+	}
+	for _, name := range names {
+		if !lokiLabelRegexp.MatchString(name) {
+			return nil, fmt.Errorf("golog: invalid Loki label name %q", name)
+		}
+	}
+
+	httpOpts := opts.HttpOptions
+	httpOpts.ContentType = "application/json"
+	httpOpts.Encode = encodeLokiPush
+	if opts.TenantID != "" {
+		header := httpOpts.Header.Clone()
+		if header == nil {
+			header = map[string][]string{}
+		}
+		header.Set("X-Scope-OrgID", opts.TenantID)
+		httpOpts.Header = header
+	}
+
+	hw, err := NewHttpWriter(strings.TrimRight(baseURL, "/")+lokiPushPath, httpOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	maxValues := opts.MaxLabelValues
+	if maxValues <= 0 {
+		maxValues = defaultLokiLabelValues
+	}
+	fieldLabels := map[string]bool{}
+	for _, key := range opts.FieldLabels {
+		fieldLabels[key] = true
+	}
+
+	dsp := NewLogDispatcher(hw)
+	defaultProcessor := NewProcessor(p, dsp, true).(*DefaultProcessor)
+	return &LokiProcessor{
+		DefaultProcessor: defaultProcessor,
+		writer:           hw,
+		labels:           opts.Labels,
+		levelLabel:       levelLabel,
+		fieldLabels:      fieldLabels,
+		maxValues:        maxValues,
+		values:           map[string]map[string]bool{},
+	}, nil
+}
@@ -0,0 +1,127 @@
+package golog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeLoki struct {
+	pushes chan lokiPushRequest
+}
+
+type lokiPushRequest struct {
+	tenant string
+	path   string
+	body   struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+}
+
+func (fl *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := lokiPushRequest{tenant: r.Header.Get("X-Scope-OrgID"), path: r.URL.Path}
+	if err := json.NewDecoder(r.Body).Decode(&req.body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	fl.pushes <- req
+}
+
+func TestLokiStreams(t *testing.T) {
+	fl := &fakeLoki{pushes: make(chan lokiPushRequest, 4)}
+	server := httptest.NewServer(fl)
+	defer server.Close()
+
+	opts := LokiOptions{
+		HttpOptions:    HttpOptions{FlushInterval: 20 * time.Millisecond},
+		Labels:         map[string]string{"job": "test"},
+		FieldLabels:    []string{"tenant"},
+		MaxLabelValues: 1,
+		TenantID:       "acme",
+	}
+	proc, err := NewLokiProcessor(server.URL+"/", LOG_INFO, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create loki processor: %s", err.Error())
+	}
+	logger := NewLogger("loki_test: ")
+	logger.AddProcessor("loki", proc)
+	defer logger.Close()
+
+	logger.With(Field{"tenant", "a"}, Field{"user", "bob smith"}).Infof("first")
+	logger.Errorf("second")
+	logger.With(Field{"tenant", "a"}).Infof("third")
+	// Over the cardinality limit, so tenant stays in the line.
+	logger.With(Field{"tenant", "b"}).Infof("fourth")
+
+	var req lokiPushRequest
+	select {
+	case req = <-fl.pushes:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for a push")
+	}
+
+	if req.path != lokiPushPath || req.tenant != "acme" {
+		t.Errorf("Unexpected push to %s for tenant %q", req.path, req.tenant)
+	}
+
+	expected := []struct {
+		labels map[string]string
+		lines  []string
+	}{
+		{map[string]string{"job": "test", "level": "info", "tenant": "a"}, []string{`loki_test: first user="bob smith"`, "loki_test: third"}},
+		{map[string]string{"job": "test", "level": "error"}, []string{"loki_test: second"}},
+		{map[string]string{"job": "test", "level": "info"}, []string{"loki_test: fourth tenant=b"}},
+	}
+	if len(req.body.Streams) != len(expected) {
+		t.Fatalf("Expected %d streams, got %+v", len(expected), req.body.Streams)
+	}
+	for i, stream := range req.body.Streams {
+		if len(stream.Stream) != len(expected[i].labels) {
+			t.Errorf("Stream %d: expected labels %v, got %v", i, expected[i].labels, stream.Stream)
+		}
+		for k, v := range expected[i].labels {
+			if stream.Stream[k] != v {
+				t.Errorf("Stream %d: expected labels %v, got %v", i, expected[i].labels, stream.Stream)
+			}
+		}
+		if len(stream.Values) != len(expected[i].lines) {
+			t.Fatalf("Stream %d: expected lines %q, got %q", i, expected[i].lines, stream.Values)
+		}
+		for j, value := range stream.Values {
+			if value[1] != expected[i].lines[j] {
+				t.Errorf("Stream %d: expected line %q, got %q", i, expected[i].lines[j], value[1])
+			}
+			if ns, err := strconv.ParseInt(value[0], 10, 64); err != nil || time.Since(time.Unix(0, ns)) > time.Minute {
+				t.Errorf("Stream %d: unexpected timestamp %q", i, value[0])
+			}
+		}
+	}
+}
+
+func TestLokiRejectsInvalidLabelNames(t *testing.T) {
+	invalid := []LokiOptions{
+		{Labels: map[string]string{"request-id": "x"}},
+		{FieldLabels: []string{"1st"}},
+		{LevelLabel: "log.level"},
+	}
+	for _, opts := range invalid {
+		if proc, err := NewLokiProcessor("http://127.0.0.1:1", LOG_INFO, opts); err == nil {
+			proc.Close()
+			t.Errorf("Expected an error for %+v", opts)
+		}
+	}
+
+	opts := LokiOptions{Labels: map[string]string{"_job": "x"}, FieldLabels: []string{"request_id"}, LevelLabel: "-"}
+	proc, err := NewLokiProcessor("http://127.0.0.1:1", LOG_INFO, opts)
+	if err != nil {
+		t.Fatalf("Valid label names were rejected: %s", err.Error())
+	}
+	proc.Close()
+}
@@ -2,8 +2,7 @@ package golog
 
 import (
 	"io"
-	"sync"
-	"bytes"
+	"sync/atomic"
 )
 
 // ***************************************************************************
@@ -17,64 +16,147 @@ import (
 // Processors need to make sure that SetPriority and GetPriority are
 // thread safe.  Use the DefaultProcessor as an example.
 //
+// Entries given to Process are pooled and reused once every processor has
+// returned, so a processor must not keep the *LogEntry or hand it to
+// another goroutine.  Copying the LogEntry is fine, its strings and Fields
+// are never modified afterwards; the RingBufferProcessor and the
+// DedupProcessor keep entries that way.
+//
 type LogProcessor interface {
 	GetPriority() Priority
 	SetPriority(Priority)
-	Process(*LogEntry)
+	Process(*LogEntry) // Must not keep the entry, see above.
 	Close() error
 	SetTimeFormat(string)
 }
 
+// Processors which write out where a message was logged from implement
+// CallerProcessor.  Looking up the caller is costly, so a Logger only fills
+// in the File and Line of its entries when one of its processors returns
+// true from WantsCaller.
+type CallerProcessor interface {
+	WantsCaller() bool
+}
+
+// Processors able to write an entry whatever their priority implement
+// unfilteredProcessor, which crash dumps rely on since the entries they
+// write were already filtered by their ring.
+type unfilteredProcessor interface {
+	processUnfiltered(entry *LogEntry)
+}
+
+// Write entry with p regardless of p's priority if p allows it, otherwise
+// only if p's priority lets it through.
+func processUnfiltered(p LogProcessor, entry *LogEntry) {
+	if up, ok := p.(unfilteredProcessor); ok {
+		up.processUnfiltered(entry)
+	} else {
+		p.Process(entry)
+	}
+}
+
+// Turns an entry into the bytes written out, appending them to buf.
+// timeFormat is the processor's time format, blank if it was never set.
+type Formatter func(buf []byte, entry *LogEntry, timeFormat string) []byte
+
+// Configuration of a DefaultProcessor.  It's never changed once published,
+// setters publish an updated copy instead, so that reading it on every
+// entry doesn't take any lock.
+type processorConfig struct {
+	priority   Priority  // Messages need to be at least this important to get through.
+	timeFormat string    // Format string for time, if blank, we use a default.
+	formatter  Formatter // If nil, entries are formatted with appendEntry.
+}
+
 type DefaultProcessor struct {
-	mu         sync.RWMutex   // Read/Write Lock used to protect the priority.
-	priority   Priority       // Messages need to be at least this important to get through.
+	config     atomic.Pointer[processorConfig]
 	Dispatcher *LogDispatcher // Dispatcher used to send messages to the channel
-	TimeFormat string         // Format string for time, if blank, we use a default.
 	Verbose    bool
 }
 
+var zeroConfig processorConfig
+
+func (df *DefaultProcessor) loadConfig() *processorConfig {
+	if config := df.config.Load(); config != nil {
+		return config
+	}
+	return &zeroConfig
+}
+
+// Publish a copy of the configuration with change applied to it.
+func (df *DefaultProcessor) updateConfig(change func(config *processorConfig)) {
+	for {
+		old := df.config.Load()
+		config := new(processorConfig)
+		if old != nil {
+			*config = *old
+		}
+		change(config)
+		if df.config.CompareAndSwap(old, config) {
+			return
+		}
+	}
+}
+
 // Atomically set the new priority.  All accesses to priority need to be
 // through GetPriority in order to maintain thread safety.
 func (df *DefaultProcessor) SetPriority(p Priority) {
 	p = BoundPriority(p)
-	df.mu.Lock()
-	df.priority = p
-	df.mu.Unlock()
+	df.updateConfig(func(config *processorConfig) { config.priority = p })
 }
 
 func (df *DefaultProcessor) GetPriority() Priority {
-	df.mu.RLock()
-	defer df.mu.RUnlock()
-	return df.priority
+	return df.loadConfig().priority
 }
 
 func (df *DefaultProcessor) SetTimeFormat(timeFormat string) {
-	df.mu.Lock()
-	df.TimeFormat = timeFormat
-	df.mu.Unlock()
+	df.updateConfig(func(config *processorConfig) { config.timeFormat = timeFormat })
+}
+
+func (df *DefaultProcessor) GetTimeFormat() string {
+	return df.loadConfig().timeFormat
+}
+
+// Replace the way entries are formatted, nil going back to the default
+// format.  Processors with a format of their own (syslog, JSON, ...) ignore
+// the formatter.
+func (df *DefaultProcessor) SetFormatter(formatter Formatter) {
+	df.updateConfig(func(config *processorConfig) { config.formatter = formatter })
 }
 
 func (df *DefaultProcessor) Process(entry *LogEntry) {
 	if entry.Priority <= df.GetPriority() {
-		time := entry.Created
-
-		var msg bytes.Buffer
+		df.processUnfiltered(entry)
+	}
+}
 
-		if len(df.TimeFormat) == 0 {
-			// Default logging format is ISO8601 with milliseconds without TZ
-			msg.WriteString(time.Format("2006-01-02 15:04:05.000"))
-		} else {
-			msg.WriteString(time.Format(df.TimeFormat))
-		}
-		msg.WriteString(" ")
-		msg.WriteString(entry.Priority.ShortString())
-		msg.WriteString(": ")
+func (df *DefaultProcessor) processUnfiltered(entry *LogEntry) {
+	config := df.loadConfig()
+	format := config.formatter
+	if format == nil {
+		format = appendEntry
+	}
 
-		msg.WriteString(entry.Prefix)
-		msg.WriteString(entry.Msg)
+	lm := df.Dispatcher.message(entry.Priority)
+	lm.msg = format(lm.msg, entry, config.timeFormat)
+	df.Dispatcher.dispatch(lm)
+}
 
-		df.Dispatcher.Send(msg.String())
+// Append an entry to buf formatted the way the DefaultProcessor writes it
+// out.
+func appendEntry(buf []byte, entry *LogEntry, timeFormat string) []byte {
+	if len(timeFormat) == 0 {
+		// Default logging format is ISO8601 with milliseconds without TZ
+		timeFormat = "2006-01-02 15:04:05.000"
 	}
+	buf = entry.Created.AppendFormat(buf, timeFormat)
+	buf = append(buf, ' ')
+	buf = append(buf, entry.Priority.ShortString()...)
+	buf = append(buf, ": "...)
+
+	buf = append(buf, entry.Prefix...)
+	buf = append(buf, entry.Msg...)
+	return appendErrorLines(buf, entry.Fields)
 }
 
 func (df *DefaultProcessor) Close() error {
@@ -84,7 +166,9 @@ func (df *DefaultProcessor) Close() error {
 // Initializers for LogProcessor
 //
 func NewProcessor(priority Priority, dispatcher *LogDispatcher, verbose bool) LogProcessor {
-	return &DefaultProcessor{priority: priority, Dispatcher: dispatcher, Verbose: verbose}
+	df := &DefaultProcessor{Dispatcher: dispatcher, Verbose: verbose}
+	df.config.Store(&processorConfig{priority: priority})
+	return df
 }
 
 func NewProcessorFromWriter(priority Priority, writer io.WriteCloser, verbose bool) LogProcessor {
@@ -93,10 +177,7 @@ func NewProcessorFromWriter(priority Priority, writer io.WriteCloser, verbose bo
 }
 
 func NewProcessorWithTimeFormat(priority Priority, dispatcher *LogDispatcher, format string) LogProcessor {
-	return &DefaultProcessor{
-		priority:   priority,
-		Dispatcher: dispatcher,
-		Verbose:    true,
-		TimeFormat: format,
-	}
+	df := &DefaultProcessor{Dispatcher: dispatcher, Verbose: true}
+	df.config.Store(&processorConfig{priority: priority, timeFormat: format})
+	return df
 }
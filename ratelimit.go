@@ -0,0 +1,236 @@
+// Log Processor wrapping another one and limiting how many similar entries
+// get through, so that a single misbehaving dependency can't flood the
+// logs.
+//
+package golog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateLimit       = 10
+	defaultSummaryInterval = time.Minute
+)
+
+// What entries are counted together by a RateLimitProcessor.
+type RateLimitKey int
+
+const (
+	RateLimitByFormat RateLimitKey = iota // Entries logged with the same format string.
+	RateLimitByPrefix                     // Entries logged with the same prefix.
+)
+
+// Options for the RateLimitProcessor.  The zero value is usable.
+type RateLimitOptions struct {
+	// Entries get through at Rate per second, 10 by default, with bursts
+	// of up to Burst entries, Rate rounded up by default.  Each priority
+	// and key has a bucket of its own.
+	Rate  float64
+	Burst int
+	Key   RateLimitKey
+
+	// Keep only one in every SampleEvery[p] entries of priority p, before
+	// rate limiting.  The first entry of every key is kept.
+	SampleEvery map[Priority]int
+
+	// Entries at least as important as Exempt skip both sampling and rate
+	// limiting, unless LimitAll is set.  Exempt is LOG_ERR when nil; it's a
+	// pointer since LOG_EMERG is the zero value.
+	Exempt   *Priority
+	LimitAll bool
+
+	// How often "suppressed N similar messages" summaries are written, once
+	// a minute by default.
+	SummaryInterval time.Duration
+}
+
+type rateBucket struct {
+	priority   Priority
+	prefix     string // Prefix of the last entry, used for its summary.
+	tokens     float64
+	last       time.Time // Last time tokens were added.
+	seen       int
+	suppressed int
+}
+
+type rateKey struct {
+	priority Priority
+	key      string
+}
+
+// ****************************************************************************
+// The RateLimitProcessor passes entries on to the processor it wraps as
+// long as their key's token bucket allows it, counting the entries it
+// drops.  Each key with dropped entries gets a summary written at every
+// SummaryInterval, and when the processor is closed.
+//
+// Priorities, time formats and closing are all handed to the wrapped
+// processor.
+//
+type RateLimitProcessor struct {
+	LogProcessor
+	opts    RateLimitOptions
+	exempt  Priority
+	now     func() time.Time // Swapped out by tests.
+	mu      sync.Mutex       // Protects the buckets.
+	buckets map[rateKey]*rateBucket
+	ticker  *time.Ticker
+	done    chan struct{}
+	closing sync.Once
+}
+
+func (rl *RateLimitProcessor) WantsCaller() bool {
+	cp, ok := rl.LogProcessor.(CallerProcessor)
+	return ok && cp.WantsCaller()
+}
+
+// Entries written regardless of priority skip the limits too.
+func (rl *RateLimitProcessor) processUnfiltered(entry *LogEntry) {
+	processUnfiltered(rl.LogProcessor, entry)
+}
+
+func (rl *RateLimitProcessor) Process(entry *LogEntry) {
+	if entry.Priority > rl.GetPriority() {
+		return
+	}
+	if !rl.opts.LimitAll && entry.Priority <= rl.exempt {
+		rl.LogProcessor.Process(entry)
+		return
+	}
+
+	if rl.allow(entry) {
+		rl.LogProcessor.Process(entry)
+	}
+}
+
+func (rl *RateLimitProcessor) key(entry *LogEntry) rateKey {
+	key := rateKey{priority: entry.Priority, key: entry.Prefix}
+	if rl.opts.Key == RateLimitByFormat {
+		key.key = entry.Format
+		if key.key == "" {
+			key.key = entry.Msg
+		}
+	}
+	return key
+}
+
+func (rl *RateLimitProcessor) allow(entry *LogEntry) bool {
+	key := rl.key(entry)
+	now := rl.now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	bucket := rl.buckets[key]
+	if bucket == nil {
+		bucket = &rateBucket{priority: entry.Priority, tokens: float64(rl.opts.Burst), last: now}
+		rl.buckets[key] = bucket
+	}
+	bucket.prefix = entry.Prefix
+	bucket.seen++
+
+	if n := rl.opts.SampleEvery[entry.Priority]; n > 1 && (bucket.seen-1)%n != 0 {
+		bucket.suppressed++
+		return false
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * rl.opts.Rate
+	if burst := float64(rl.opts.Burst); bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.last = now
+	if bucket.tokens < 1 {
+		bucket.suppressed++
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// Write a summary for every key with suppressed entries, and forget the
+// keys which were quiet since the last summaries.
+func (rl *RateLimitProcessor) summarize() {
+	var summaries []*LogEntry
+	now := rl.now()
+
+	rl.mu.Lock()
+	for key, bucket := range rl.buckets {
+		if bucket.suppressed == 0 {
+			if bucket.seen == 0 {
+				delete(rl.buckets, key)
+			}
+			bucket.seen = 0
+			continue
+		}
+		summaries = append(summaries, &LogEntry{
+			Prefix:   bucket.prefix,
+			Priority: bucket.priority,
+			Msg:      fmt.Sprintf("Suppressed %d similar messages: %s\n", bucket.suppressed, strings.TrimRight(key.key, "\n")),
+			Created:  now,
+		})
+		bucket.seen, bucket.suppressed = 0, 0
+	}
+	rl.mu.Unlock()
+
+	for _, summary := range summaries {
+		rl.LogProcessor.Process(summary)
+	}
+}
+
+func (rl *RateLimitProcessor) run() {
+	for {
+		select {
+		case <-rl.ticker.C:
+			rl.summarize()
+		case <-rl.done:
+			return
+		}
+	}
+}
+
+// Write the pending summaries, then close the wrapped processor.
+func (rl *RateLimitProcessor) Close() error {
+	rl.closing.Do(func() {
+		rl.ticker.Stop()
+		close(rl.done)
+		rl.summarize()
+	})
+	return rl.LogProcessor.Close()
+}
+
+// Create a RateLimitProcessor passing the entries its limits allow on to
+// proc.
+func NewRateLimitProcessor(proc LogProcessor, opts RateLimitOptions) *RateLimitProcessor {
+	if opts.Rate <= 0 {
+		opts.Rate = defaultRateLimit
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = int(opts.Rate)
+		if float64(opts.Burst) < opts.Rate {
+			opts.Burst++
+		}
+	}
+	exempt := LOG_ERR
+	if opts.Exempt != nil {
+		exempt = *opts.Exempt
+	}
+	if opts.SummaryInterval <= 0 {
+		opts.SummaryInterval = defaultSummaryInterval
+	}
+
+	rl := &RateLimitProcessor{
+		LogProcessor: proc,
+		opts:         opts,
+		exempt:       exempt,
+		now:          time.Now,
+		buckets:      map[rateKey]*rateBucket{},
+		ticker:       time.NewTicker(opts.SummaryInterval),
+		done:         make(chan struct{}),
+	}
+	go rl.run()
+	return rl
+}
@@ -0,0 +1,93 @@
+package golog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitProcessor(t *testing.T) {
+	chw := NewChanWriter()
+	proc := NewRateLimitProcessor(NewProcessorFromWriter(LOG_DEBUG, chw, true), RateLimitOptions{
+		Rate:        1,
+		Burst:       2,
+		SampleEvery: map[Priority]int{LOG_DEBUG: 3},
+	})
+	now := time.Now()
+	proc.now = func() time.Time { return now }
+
+	logger := NewLogger("limit: ")
+	logger.AddProcessor("limit", proc)
+	for i := 0; i < 5; i++ {
+		logger.Warningf("upstream timed out after %dms", i)
+		logger.Errorf("exempt %d", i)
+	}
+	now = now.Add(time.Second)
+	logger.Warningf("upstream timed out after %dms", 5)
+	for i := 0; i < 6; i++ {
+		logger.Debugf("sampled %d", i)
+	}
+	proc.summarize()
+
+	var lines []string
+	for len(lines) < 12 {
+		select {
+		case line := <-chw.msg:
+			lines = append(lines, line[strings.Index(line, "limit: "):])
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("Expected 12 lines, got %q", lines)
+		}
+	}
+	expected := []string{
+		"limit: upstream timed out after 0ms\n",
+		"limit: exempt 0\n",
+		"limit: upstream timed out after 1ms\n",
+		"limit: exempt 1\n",
+		"limit: exempt 2\n",
+		"limit: exempt 3\n",
+		"limit: exempt 4\n",
+		"limit: upstream timed out after 5ms\n",
+		"limit: sampled 0\n",
+		"limit: sampled 3\n",
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("Expected %q, got %q", line, lines[i])
+		}
+	}
+
+	// Summaries come in no particular order.
+	summaries := strings.Join(lines[len(expected):], "")
+	for _, summary := range []string{
+		"limit: Suppressed 3 similar messages: upstream timed out after %dms\n",
+		"limit: Suppressed 4 similar messages: sampled %d\n",
+	} {
+		if !strings.Contains(summaries, summary) {
+			t.Errorf("Missing summary %q in %q", summary, summaries)
+		}
+	}
+}
+
+func TestRateLimitExemptsEmergencyOnly(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	exempt := LOG_EMERG
+	proc := NewRateLimitProcessor(ring, RateLimitOptions{Rate: 1, Burst: 1, Exempt: &exempt})
+	now := time.Now()
+	proc.now = func() time.Time { return now }
+
+	logger := NewLogger("limit: ")
+	logger.AddProcessor("limit", proc)
+	for i := 0; i < 2; i++ {
+		logger.Errorf("limited")
+		logger.Emergencyf("exempt")
+	}
+
+	var msgs []string
+	for _, entry := range ring.Snapshot() {
+		msgs = append(msgs, entry.Msg)
+	}
+	expected := []string{"limited\n", "exempt\n", "exempt\n"}
+	if strings.Join(msgs, "") != strings.Join(expected, "") {
+		t.Errorf("Expected %q, got %q", expected, msgs)
+	}
+}
@@ -0,0 +1,245 @@
+package golog
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errWriterClosed = errors.New("golog: write to closed writer")
+
+// Options used by writers which keep trying to reach their resource after
+// it goes away.  The zero value gives sensible defaults.
+type ReconnectOptions struct {
+	MinBackoff time.Duration // First retry delay, defaults to 100ms.
+	MaxBackoff time.Duration // Longest retry delay, defaults to 30s.
+
+	// Bounds of the buffer holding messages while disconnected.  They
+	// default to 1024 messages and 1MB.  A negative BufferMessages turns
+	// buffering off, and messages are dropped during outages.
+	BufferMessages int
+	BufferBytes    int
+
+	// If set, messages are buffered in this file instead of in memory, so
+	// that they also survive a restart of the process.  Only BufferBytes
+	// bounds the spool file.
+	SpoolFile string
+}
+
+// Counters kept by writers which survive outages.
+type WriterStats struct {
+	Written    uint64 // Messages written straight to the connection.
+	Buffered   uint64 // Messages held back while disconnected.
+	Replayed   uint64 // Held back messages delivered after reconnecting.
+	Dropped    uint64 // Messages lost because the buffer was full or disabled.
+	Reconnects uint64 // Successful dials after losing the connection.
+}
+
+// ****************************************************************************
+// The reconnectWriter wraps a connection produced by dial.  When a write
+// fails it holds the message and redials in the background, right away in
+// case the other end simply restarted, and then with a jittered exponential
+// backoff.  Once the connection is back, held messages are replayed before
+// any new ones are written.
+//
+// Dialing and replaying happen without holding the lock, so Write never
+// blocks on them or on the backoff, and the log channel keeps draining
+// during an outage.
+//
+type reconnectWriter struct {
+	mu      sync.Mutex
+	dial    func() (io.WriteCloser, error)
+	conn    io.WriteCloser // nil while disconnected.
+	backoff Backoff
+	buffer  outageBuffer
+	retry   *time.Timer // Pending reconnect attempt, if any.
+	dialing bool        // Whether a reconnect attempt is under way.
+	closed  bool
+	stats   WriterStats // Only accessed atomically.
+}
+
+func newReconnectWriter(dial func() (io.WriteCloser, error), opts ReconnectOptions) (*reconnectWriter, error) {
+	buffer, err := newOutageBuffer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dial()
+	if err != nil {
+		buffer.close()
+		return nil, err
+	}
+
+	rw := &reconnectWriter{
+		dial:    dial,
+		backoff: Backoff{Min: opts.MinBackoff, Max: opts.MaxBackoff},
+		buffer:  buffer,
+	}
+
+	// A spool file may hold messages from a previous run.
+	if rw.resume(conn) != nil {
+		conn.Close()
+		rw.mu.Lock()
+		rw.scheduleRetry(rw.backoff.Next())
+		rw.mu.Unlock()
+	}
+	return rw, nil
+}
+
+func (rw *reconnectWriter) Write(data []byte) (n int, err error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.closed {
+		return 0, errWriterClosed
+	}
+
+	// While connected the buffer is always empty, so ordering is kept.
+	if rw.conn != nil {
+		if n, err = rw.conn.Write(data); err == nil {
+			atomic.AddUint64(&rw.stats.Written, 1)
+			return n, nil
+		}
+		rw.disconnect()
+		rw.scheduleRetry(0)
+	}
+
+	rw.hold(data)
+	return len(data), nil
+}
+
+func (rw *reconnectWriter) Close() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.closed {
+		return nil
+	}
+	rw.closed = true
+	if rw.retry != nil {
+		rw.retry.Stop()
+		rw.retry = nil
+	}
+
+	// Held messages are lost unless they're spooled to disk.
+	if _, spooled := rw.buffer.(*diskBuffer); !spooled {
+		atomic.AddUint64(&rw.stats.Dropped, uint64(rw.buffer.len()))
+	}
+	rw.buffer.close()
+
+	if rw.conn != nil {
+		err := rw.conn.Close()
+		rw.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Get a snapshot of the writer's counters.
+func (rw *reconnectWriter) Stats() WriterStats {
+	return WriterStats{
+		Written:    atomic.LoadUint64(&rw.stats.Written),
+		Buffered:   atomic.LoadUint64(&rw.stats.Buffered),
+		Replayed:   atomic.LoadUint64(&rw.stats.Replayed),
+		Dropped:    atomic.LoadUint64(&rw.stats.Dropped),
+		Reconnects: atomic.LoadUint64(&rw.stats.Reconnects),
+	}
+}
+
+// Background reconnect attempt, run by the retry timer.
+func (rw *reconnectWriter) reconnect() {
+	rw.mu.Lock()
+	rw.retry = nil
+	if rw.closed || rw.conn != nil || rw.dialing {
+		rw.mu.Unlock()
+		return
+	}
+	rw.dialing = true
+	rw.mu.Unlock()
+
+	conn, err := rw.dial()
+	if err == nil {
+		atomic.AddUint64(&rw.stats.Reconnects, 1)
+		if err = rw.resume(conn); err != nil {
+			conn.Close()
+		}
+	}
+
+	rw.mu.Lock()
+	rw.dialing = false
+	if rw.conn == nil {
+		// Either this attempt failed, or the connection was lost again
+		// before we were done, and the retry it scheduled bailed out.
+		rw.scheduleRetry(rw.backoff.Next())
+	}
+	rw.mu.Unlock()
+}
+
+// Replay the held messages to conn, and once none are left, start writing
+// to it.  The messages are written without holding the lock, so new ones
+// keep being held meanwhile, and are replayed on the next round.
+func (rw *reconnectWriter) resume(conn io.WriteCloser) error {
+	for {
+		rw.mu.Lock()
+		if rw.closed {
+			rw.mu.Unlock()
+			return errWriterClosed
+		}
+		if rw.buffer.len() == 0 {
+			rw.conn = conn
+			rw.backoff.Reset()
+			rw.mu.Unlock()
+			return nil
+		}
+		msgs, first, err := rw.buffer.peek()
+		rw.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		written := 0
+		for _, data := range msgs {
+			if _, err = conn.Write(data); err != nil {
+				break
+			}
+			written++
+		}
+		atomic.AddUint64(&rw.stats.Replayed, uint64(written))
+
+		rw.mu.Lock()
+		if !rw.closed {
+			if derr := rw.buffer.discard(first + uint64(written)); err == nil {
+				err = derr
+			}
+		}
+		rw.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// The following methods must be called with rw.mu held.
+
+func (rw *reconnectWriter) hold(data []byte) {
+	stored, dropped := rw.buffer.push(data)
+	if stored {
+		atomic.AddUint64(&rw.stats.Buffered, 1)
+	}
+	atomic.AddUint64(&rw.stats.Dropped, uint64(dropped))
+}
+
+func (rw *reconnectWriter) disconnect() {
+	if rw.conn != nil {
+		rw.conn.Close()
+		rw.conn = nil
+	}
+}
+
+func (rw *reconnectWriter) scheduleRetry(delay time.Duration) {
+	if rw.retry == nil && !rw.closed {
+		rw.retry = time.AfterFunc(delay, rw.reconnect)
+	}
+}
@@ -0,0 +1,190 @@
+package golog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A fake collector which can be taken down and brought back up.
+type flakyCollector struct {
+	mu   sync.Mutex
+	down bool
+	got  bytes.Buffer
+}
+
+type flakyConn struct {
+	c *flakyCollector
+}
+
+func (fc *flakyConn) Write(b []byte) (int, error) {
+	fc.c.mu.Lock()
+	defer fc.c.mu.Unlock()
+	if fc.c.down {
+		return 0, errors.New("collector is down")
+	}
+	return fc.c.got.Write(b)
+}
+
+func (fc *flakyConn) Close() error {
+	return nil
+}
+
+func (c *flakyCollector) dial() (io.WriteCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return nil, errors.New("collector is down")
+	}
+	return &flakyConn{c: c}, nil
+}
+
+func (c *flakyCollector) setDown(down bool) {
+	c.mu.Lock()
+	c.down = down
+	c.mu.Unlock()
+}
+
+func (c *flakyCollector) received() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.got.String()
+}
+
+func waitForReceived(c *flakyCollector, expected string, t *testing.T) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if c.received() == expected {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Collector never received the expected data.\nExpected '%s'\nResult   '%s'", expected, c.received())
+}
+
+func TestReconnectReplaysBuffer(t *testing.T) {
+	c := &flakyCollector{}
+	opts := ReconnectOptions{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	rw, err := newReconnectWriter(c.dial, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create writer: %s", err.Error())
+	}
+	defer rw.Close()
+
+	rw.Write([]byte("one\n"))
+	c.setDown(true)
+	rw.Write([]byte("two\n"))
+	rw.Write([]byte("three\n"))
+	c.setDown(false)
+
+	waitForReceived(c, "one\ntwo\nthree\n", t)
+	rw.Write([]byte("four\n"))
+	waitForReceived(c, "one\ntwo\nthree\nfour\n", t)
+
+	stats := rw.Stats()
+	if stats.Buffered != 2 || stats.Replayed != 2 || stats.Dropped != 0 || stats.Reconnects != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestReconnectDoesntBlockWrites(t *testing.T) {
+	c := &flakyCollector{}
+	unblock := make(chan struct{})
+	dials := 0
+	dial := func() (io.WriteCloser, error) {
+		if dials++; dials > 1 {
+			// Like a TCP dial to an unreachable shipper.
+			<-unblock
+		}
+		return c.dial()
+	}
+	opts := ReconnectOptions{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	rw, err := newReconnectWriter(dial, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create writer: %s", err.Error())
+	}
+	defer rw.Close()
+
+	c.setDown(true)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			rw.Write([]byte("held\n"))
+			time.Sleep(time.Millisecond)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Writes blocked while redialing")
+	}
+
+	c.setDown(false)
+	close(unblock)
+	waitForReceived(c, strings.Repeat("held\n", 10), t)
+}
+
+func TestReconnectDropsOldest(t *testing.T) {
+	c := &flakyCollector{}
+	opts := ReconnectOptions{MinBackoff: time.Hour, BufferMessages: 2}
+	rw, err := newReconnectWriter(c.dial, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create writer: %s", err.Error())
+	}
+
+	c.setDown(true)
+	for _, msg := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := rw.Write([]byte(msg)); err != nil {
+			t.Errorf("Write shouldn't fail during an outage: %s", err.Error())
+		}
+	}
+	c.setDown(false)
+	rw.reconnect()
+	rw.Close()
+
+	if c.received() != "b\nc\n" {
+		t.Errorf("Expected only the newest messages to be replayed, got '%s'", c.received())
+	}
+	if stats := rw.Stats(); stats.Dropped != 1 {
+		t.Errorf("Expected 1 dropped message, got %d", stats.Dropped)
+	}
+}
+
+func TestReconnectSpoolFile(t *testing.T) {
+	spool, err := ioutil.TempFile("", "golog_spool_test")
+	if err != nil {
+		t.Fatalf("Couldn't create spool file: %s", err.Error())
+	}
+	spool.Close()
+	defer os.Remove(spool.Name())
+
+	c := &flakyCollector{}
+	opts := ReconnectOptions{MinBackoff: time.Hour, SpoolFile: spool.Name()}
+	rw, err := newReconnectWriter(c.dial, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create writer: %s", err.Error())
+	}
+	c.setDown(true)
+	rw.Write([]byte("spooled\n"))
+	rw.Close()
+
+	// A new writer picks up what the previous one left on disk.
+	c.setDown(false)
+	rw, err = newReconnectWriter(c.dial, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create writer: %s", err.Error())
+	}
+	rw.Write([]byte("fresh\n"))
+	rw.Close()
+
+	if c.received() != "spooled\nfresh\n" {
+		t.Errorf("Spooled message wasn't replayed first, got '%s'", c.received())
+	}
+}
@@ -0,0 +1,56 @@
+// Helpers logging panics before they go unnoticed or take the process down.
+//
+package golog
+
+import (
+	"runtime/debug"
+)
+
+// Log the panic value r at priority p, with the stack of the panicking go
+// routine, and wait for it to be written.  Errors are attached with Err.
+func logPanic(logger *Logger, p Priority, r interface{}) {
+	if err, ok := r.(error); ok {
+		logger = logger.Err(err)
+	}
+	// Skip logPanic, Recover and the runtime's panic handling, so that
+	// processors wanting the caller get where the panic happened.
+	logger.logf(3, p, "panic: %v\n%s", r, debug.Stack())
+	FlushLogs()
+}
+
+// Recover from a panic and log it at priority p along with the stack.  It
+// must be deferred directly:
+//
+//	defer golog.Recover(logger, golog.LOG_CRIT)
+//
+func Recover(logger *Logger, p Priority) {
+	if r := recover(); r != nil {
+		logPanic(logger, p, r)
+	}
+}
+
+// Same as Recover, but carry on panicking once the panic is logged.
+func RecoverAndRepanic(logger *Logger, p Priority) {
+	if r := recover(); r != nil {
+		logPanic(logger, p, r)
+		panic(r)
+	}
+}
+
+// Run f in a new go routine, logging at LOG_CRIT rather than crashing the
+// process if it panics.
+func Go(logger *Logger, f func()) {
+	go func() {
+		defer Recover(logger, LOG_CRIT)
+		f()
+	}()
+}
+
+// Same as Go, but the panic carries on once logged, which ends the process
+// unless f recovers from it itself.
+func GoAndRepanic(logger *Logger, f func()) {
+	go func() {
+		defer RecoverAndRepanic(logger, LOG_CRIT)
+		f()
+	}()
+}
@@ -0,0 +1,69 @@
+package golog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecoverLogsPanic(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	logger := NewLogger("recover: ")
+	logger.AddProcessor("ring", ring)
+
+	func() {
+		defer Recover(logger, LOG_ALERT)
+		panic("out of widgets")
+	}()
+
+	entries := ring.Snapshot()
+	if len(entries) != 1 || entries[0].Priority != LOG_ALERT {
+		t.Fatalf("Expected a single alert, got %q", ringMsgs(entries))
+	}
+	msg := entries[0].Msg
+	if !strings.HasPrefix(msg, "panic: out of widgets\n") || !strings.Contains(msg, "TestRecoverLogsPanic") {
+		t.Errorf("Expected the panic value and stack, got %q", msg)
+	}
+}
+
+func TestRecoverAndRepanic(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	logger := NewLogger("recover: ")
+	logger.AddProcessor("ring", ring)
+	boom := errors.New("boom")
+
+	func() {
+		defer func() {
+			if r := recover(); r != boom {
+				t.Errorf("Expected the panic to carry on, got %v", r)
+			}
+		}()
+		defer RecoverAndRepanic(logger, LOG_CRIT)
+		panic(boom)
+	}()
+
+	entries := ring.Snapshot()
+	if len(entries) != 1 || len(entries[0].Fields) != 1 {
+		t.Fatalf("Expected a single entry with the error attached, got %+v", entries)
+	}
+	if details := entries[0].Fields[0].Value.(ErrorDetails); details.Message != "boom" {
+		t.Errorf("Unexpected error details %+v", details)
+	}
+}
+
+func TestGoRecovers(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	logger := NewLogger("recover: ")
+	logger.AddProcessor("ring", ring)
+
+	done := make(chan bool)
+	Go(logger, func() {
+		defer close(done)
+		panic("in the background")
+	})
+	<-done
+	waitFor(t, "the panic to be logged", func() bool { return ring.Len() == 1 })
+	if entry := ring.Snapshot()[0]; entry.Priority != LOG_CRIT || !strings.HasPrefix(entry.Msg, "panic: in the background\n") {
+		t.Errorf("Unexpected entry %s %q", entry.Priority, entry.Msg)
+	}
+}
@@ -0,0 +1,139 @@
+// Log Processor keeping the most recent entries in memory, so that they can
+// be looked at when something goes wrong even if they were never written
+// anywhere else.
+//
+package golog
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultRingSize = 4096
+
+// Filters used to pick entries out of a RingBufferProcessor.
+type RingFilter func(entry *LogEntry) bool
+
+// Entries created at or after t.
+func RingSince(t time.Time) RingFilter {
+	return func(entry *LogEntry) bool { return !entry.Created.Before(t) }
+}
+
+// Entries created before t.
+func RingUntil(t time.Time) RingFilter {
+	return func(entry *LogEntry) bool { return entry.Created.Before(t) }
+}
+
+// Entries at least as important as p.
+func RingAtLeast(p Priority) RingFilter {
+	return func(entry *LogEntry) bool { return entry.Priority <= p }
+}
+
+// Entries whose prefix contains substr.
+func RingPrefix(substr string) RingFilter {
+	return func(entry *LogEntry) bool { return strings.Contains(entry.Prefix, substr) }
+}
+
+// ****************************************************************************
+// The RingBufferProcessor keeps a copy of the last entries it accepted in a
+// fixed size ring.  Processing an entry is a copy under a lock, nothing is
+// formatted or written, so it's cheap enough to leave on at LOG_DEBUG while
+// other processors log at a higher priority.
+//
+type RingBufferProcessor struct {
+	priority atomic.Int32
+
+	ringMu  sync.Mutex
+	entries []LogEntry
+	next    int  // Slot the next entry goes to.
+	full    bool // Whether every slot was written to at least once.
+}
+
+func (rb *RingBufferProcessor) SetPriority(p Priority) {
+	rb.priority.Store(int32(BoundPriority(p)))
+}
+
+func (rb *RingBufferProcessor) GetPriority() Priority {
+	return Priority(rb.priority.Load())
+}
+
+// Entries are kept as they are, so there's no time format to set.
+func (rb *RingBufferProcessor) SetTimeFormat(string) {}
+
+func (rb *RingBufferProcessor) Process(entry *LogEntry) {
+	if entry.Priority <= rb.GetPriority() {
+		rb.processUnfiltered(entry)
+	}
+}
+
+func (rb *RingBufferProcessor) processUnfiltered(entry *LogEntry) {
+	rb.ringMu.Lock()
+	rb.entries[rb.next] = *entry
+	rb.next++
+	if rb.next == len(rb.entries) {
+		rb.next, rb.full = 0, true
+	}
+	rb.ringMu.Unlock()
+}
+
+func (rb *RingBufferProcessor) Close() error {
+	rb.Reset()
+	return nil
+}
+
+// Get the kept entries matching every filter given, oldest first.
+func (rb *RingBufferProcessor) Snapshot(filters ...RingFilter) []LogEntry {
+	rb.ringMu.Lock()
+	defer rb.ringMu.Unlock()
+
+	var matched []LogEntry
+	collect := func(entries []LogEntry) {
+	next:
+		for i := range entries {
+			for _, filter := range filters {
+				if !filter(&entries[i]) {
+					continue next
+				}
+			}
+			matched = append(matched, entries[i])
+		}
+	}
+	if rb.full {
+		collect(rb.entries[rb.next:])
+	}
+	collect(rb.entries[:rb.next])
+	return matched
+}
+
+// Number of entries kept.
+func (rb *RingBufferProcessor) Len() int {
+	rb.ringMu.Lock()
+	defer rb.ringMu.Unlock()
+	if rb.full {
+		return len(rb.entries)
+	}
+	return rb.next
+}
+
+// Forget every entry kept.
+func (rb *RingBufferProcessor) Reset() {
+	rb.ringMu.Lock()
+	for i := range rb.entries {
+		rb.entries[i] = LogEntry{}
+	}
+	rb.next, rb.full = 0, false
+	rb.ringMu.Unlock()
+}
+
+// Create a RingBufferProcessor keeping the last size entries, 4096 if size
+// isn't positive.
+func NewRingBufferProcessor(size int, p Priority) *RingBufferProcessor {
+	if size <= 0 {
+		size = defaultRingSize
+	}
+	rb := &RingBufferProcessor{entries: make([]LogEntry, size)}
+	rb.SetPriority(p)
+	return rb
+}
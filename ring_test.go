@@ -0,0 +1,79 @@
+package golog
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func ringMsgs(entries []LogEntry) []string {
+	msgs := make([]string, len(entries))
+	for i, entry := range entries {
+		msgs[i] = entry.Msg
+	}
+	return msgs
+}
+
+func TestRingBufferKeepsLatest(t *testing.T) {
+	ring := NewRingBufferProcessor(3, LOG_DEBUG)
+	logger := NewLogger("ring: ")
+	logger.AddProcessor("ring", ring)
+
+	for i := 0; i < 5; i++ {
+		logger.Debugf("%d", i)
+	}
+	if ring.Len() != 3 {
+		t.Errorf("Expected 3 entries, got %d", ring.Len())
+	}
+	msgs := ringMsgs(ring.Snapshot())
+	if len(msgs) != 3 || msgs[0] != "2\n" || msgs[1] != "3\n" || msgs[2] != "4\n" {
+		t.Errorf("Expected the last 3 entries, got %q", msgs)
+	}
+
+	ring.SetPriority(LOG_INFO)
+	logger.Debugf("filtered")
+	if msgs := ringMsgs(ring.Snapshot()); msgs[2] != "4\n" {
+		t.Errorf("Entry below the ring's priority was kept: %q", msgs)
+	}
+}
+
+func TestRingBufferFilters(t *testing.T) {
+	ring := NewRingBufferProcessor(10, LOG_DEBUG)
+	start := time.Now()
+	for i, p := range Priorities() {
+		prefix := "even: "
+		if i%2 == 1 {
+			prefix = "odd: "
+		}
+		ring.Process(&LogEntry{
+			Prefix:   prefix,
+			Priority: p,
+			Msg:      strconv.Itoa(i),
+			Created:  start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	checks := []struct {
+		filters  []RingFilter
+		expected string
+	}{
+		{[]RingFilter{RingAtLeast(LOG_ERR)}, "0123"},
+		{[]RingFilter{RingPrefix("odd")}, "1357"},
+		{[]RingFilter{RingSince(start.Add(2 * time.Second)), RingUntil(start.Add(5 * time.Second))}, "234"},
+		{[]RingFilter{RingAtLeast(LOG_WARNING), RingPrefix("even")}, "024"},
+	}
+	for _, check := range checks {
+		got := ""
+		for _, entry := range ring.Snapshot(check.filters...) {
+			got += entry.Msg
+		}
+		if got != check.expected {
+			t.Errorf("Expected entries %s, got %s", check.expected, got)
+		}
+	}
+
+	ring.Reset()
+	if ring.Len() != 0 || len(ring.Snapshot()) != 0 {
+		t.Errorf("Reset didn't empty the ring")
+	}
+}
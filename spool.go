@@ -0,0 +1,246 @@
+package golog
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	defaultBufferMessages = 1024
+	defaultBufferBytes    = 1 << 20
+)
+
+// ****************************************************************************
+// An outageBuffer holds messages back while a writer can't reach its
+// resource, and hands them out again, oldest first, once it can.  Both
+// implementations are bounded and are only ever used under the lock of the
+// writer owning them.
+//
+// Every message gets a sequence number as it's pushed, so that the writer
+// can peek at the held messages, write them out without holding its lock,
+// and only then discard them, even if older messages were dropped in the
+// meantime.
+//
+type outageBuffer interface {
+	// Store a copy of data.  Dropped counts the messages lost to respect
+	// the buffer's bounds, including data itself if it wasn't stored.
+	push(data []byte) (stored bool, dropped int)
+
+	// Get every held message in order, along with the sequence number of
+	// the first one.  The messages must not be modified.
+	peek() (msgs [][]byte, first uint64, err error)
+
+	// Discard the held messages whose sequence number is below upto.
+	discard(upto uint64) error
+
+	len() int
+	close() error
+}
+
+func newOutageBuffer(opts ReconnectOptions) (outageBuffer, error) {
+	maxMsgs, maxBytes := opts.BufferMessages, opts.BufferBytes
+	if maxMsgs == 0 {
+		maxMsgs = defaultBufferMessages
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultBufferBytes
+	}
+	if maxMsgs < 0 {
+		maxMsgs, maxBytes = 0, 0
+	}
+
+	if opts.SpoolFile != "" && maxMsgs > 0 {
+		return openDiskBuffer(opts.SpoolFile, maxBytes)
+	}
+	return &memBuffer{maxMsgs: maxMsgs, maxBytes: maxBytes}, nil
+}
+
+// ****************************************************************************
+// memBuffer keeps held messages in memory.  When full, the oldest messages
+// are dropped in favor of the newest ones.
+//
+type memBuffer struct {
+	msgs     [][]byte
+	first    uint64 // Sequence number of msgs[0].
+	size     int
+	maxMsgs  int
+	maxBytes int
+}
+
+func (mb *memBuffer) push(data []byte) (stored bool, dropped int) {
+	if len(data) > mb.maxBytes || mb.maxMsgs <= 0 {
+		return false, 1
+	}
+	for len(mb.msgs) >= mb.maxMsgs || mb.size+len(data) > mb.maxBytes {
+		mb.pop()
+		dropped++
+	}
+	mb.msgs = append(mb.msgs, append([]byte(nil), data...))
+	mb.size += len(data)
+	return true, dropped
+}
+
+func (mb *memBuffer) peek() (msgs [][]byte, first uint64, err error) {
+	return append([][]byte(nil), mb.msgs...), mb.first, nil
+}
+
+func (mb *memBuffer) discard(upto uint64) error {
+	for len(mb.msgs) > 0 && mb.first < upto {
+		mb.pop()
+	}
+	return nil
+}
+
+func (mb *memBuffer) pop() {
+	mb.size -= len(mb.msgs[0])
+	mb.msgs[0] = nil
+	mb.msgs = mb.msgs[1:]
+	mb.first++
+}
+
+func (mb *memBuffer) len() int {
+	return len(mb.msgs)
+}
+
+func (mb *memBuffer) close() error {
+	mb.msgs, mb.size = nil, 0
+	return nil
+}
+
+// ****************************************************************************
+// diskBuffer keeps held messages in a spool file so that they survive a
+// restart of the process.  Each record is a 4 byte big endian length
+// followed by the message.  Unlike memBuffer, once the file is full we drop
+// the newest messages since rewriting the file for every push is too costly.
+//
+type diskBuffer struct {
+	f        *os.File
+	first    uint64 // Sequence number of the first record.
+	size     int
+	count    int
+	maxBytes int
+}
+
+const spoolHeaderLen = 4
+
+func openDiskBuffer(filename string, maxBytes int) (*diskBuffer, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, os.FileMode(defaultFilePerms))
+	if err != nil {
+		return nil, err
+	}
+	db := &diskBuffer{f: f, maxBytes: maxBytes}
+
+	// Pick up whatever a previous run left behind.
+	data, err := db.readAll()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	records, valid := splitSpoolRecords(data)
+	if valid != len(data) {
+		// Drop a partially written trailing record.
+		if err = db.rewrite(data[:valid]); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	db.size, db.count = valid, len(records)
+	return db, nil
+}
+
+func (db *diskBuffer) push(data []byte) (stored bool, dropped int) {
+	if db.size+spoolHeaderLen+len(data) > db.maxBytes {
+		return false, 1
+	}
+	record := make([]byte, spoolHeaderLen+len(data))
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	copy(record[spoolHeaderLen:], data)
+	if _, err := db.f.Write(record); err != nil {
+		return false, 1
+	}
+	db.size += len(record)
+	db.count++
+	return true, 0
+}
+
+func (db *diskBuffer) peek() (msgs [][]byte, first uint64, err error) {
+	if db.count == 0 {
+		return nil, db.first, nil
+	}
+	data, err := db.readAll()
+	if err != nil {
+		return nil, db.first, err
+	}
+	msgs, _ = splitSpoolRecords(data)
+	return msgs, db.first, nil
+}
+
+func (db *diskBuffer) discard(upto uint64) error {
+	if upto <= db.first || db.count == 0 {
+		return nil
+	}
+	data, err := db.readAll()
+	if err != nil {
+		return err
+	}
+
+	offset, discarded := 0, 0
+	records, _ := splitSpoolRecords(data)
+	for _, record := range records {
+		if db.first+uint64(discarded) >= upto {
+			break
+		}
+		offset += spoolHeaderLen + len(record)
+		discarded++
+	}
+
+	if err = db.rewrite(data[offset:]); err != nil {
+		return err
+	}
+	db.size -= offset
+	db.count -= discarded
+	db.first += uint64(discarded)
+	return nil
+}
+
+func (db *diskBuffer) len() int {
+	return db.count
+}
+
+func (db *diskBuffer) close() error {
+	return db.f.Close()
+}
+
+func (db *diskBuffer) readAll() ([]byte, error) {
+	if _, err := db.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(db.f)
+}
+
+// Replace the contents of the spool file with data.  The file is opened in
+// append mode, so writes after the truncate land at the start.
+func (db *diskBuffer) rewrite(data []byte) error {
+	if err := db.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := db.f.Write(data)
+	return err
+}
+
+// Break spooled data up into its records, also returning how many bytes of
+// data made up complete records.
+func splitSpoolRecords(data []byte) (records [][]byte, valid int) {
+	for len(data)-valid >= spoolHeaderLen {
+		n := int(binary.BigEndian.Uint32(data[valid:]))
+		end := valid + spoolHeaderLen + n
+		if n < 0 || end > len(data) {
+			break
+		}
+		records = append(records, data[valid+spoolHeaderLen:end])
+		valid = end
+	}
+	return records, valid
+}
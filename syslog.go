@@ -35,49 +35,14 @@ func SyslogFacilities() []Facility {
 		LOCAL7}
 }
 
+// ****************************************************************************
+// The SyslogWriter writes to the syslog daemon at the network and address it
+// was dialed with.  If the daemon goes away, we keep reconnecting to that
+// same address in the background and buffer messages in the meantime (see
+// reconnectWriter).
+//
 type SyslogWriter struct {
-	syslogConn io.WriteCloser
-}
-
-func (sw *SyslogWriter) reconnect() error {
-	if sw.syslogConn != nil {
-		sw.syslogConn.Close()
-	}
-
-	newSyslogConn, err := dialSyslog("", "")
-	if err != nil {
-		sw.syslogConn = nil
-		return err
-	}
-
-	sw.syslogConn = newSyslogConn
-	return nil
-}
-
-func (sw *SyslogWriter) Write(data []byte) (n int, err error) {
-	if sw.syslogConn == nil {
-		err = sw.reconnect()
-		if err != nil {
-			return 0, err
-		}
-	}
-
-	n, err = sw.syslogConn.Write(data)
-	if err != nil {
-		err = sw.reconnect()
-		if err != nil {
-			return 0, err
-		}
-		return sw.syslogConn.Write(data)
-	}
-	return n, err
-}
-
-func (sw *SyslogWriter) Close() (err error) {
-	if sw.syslogConn != nil {
-		return sw.syslogConn.Close()
-	}
-	return nil
+	*reconnectWriter
 }
 
 // Create a socket connection to the syslog
@@ -104,11 +69,22 @@ func dialSyslog(network, raddr string) (sock net.Conn, err error) {
 }
 
 func DialSyslog(network, raddr string) (sock io.WriteCloser, err error) {
-	syslogConn, err := dialSyslog(network, raddr)
+	sw, err := DialSyslogWithOptions(network, raddr, ReconnectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func DialSyslogWithOptions(network, raddr string, opts ReconnectOptions) (*SyslogWriter, error) {
+	dial := func() (io.WriteCloser, error) {
+		return dialSyslog(network, raddr)
+	}
+	rw, err := newReconnectWriter(dial, opts)
 	if err != nil {
 		return nil, err
 	}
-	return &SyslogWriter{syslogConn: syslogConn}, nil
+	return &SyslogWriter{reconnectWriter: rw}, nil
 }
 
 // ****************************************************************************
@@ -120,6 +96,13 @@ func DialSyslog(network, raddr string) (sock io.WriteCloser, err error) {
 type SyslogProcessor struct {
 	*DefaultProcessor
 	facility Facility
+	writer   *SyslogWriter
+}
+
+// Get the counters of the underlying SyslogWriter, such as how many
+// messages were dropped while syslog was unreachable.
+func (su *SyslogProcessor) Stats() WriterStats {
+	return su.writer.Stats()
 }
 
 // Not only do we filter out messages whose priority is not high enough
@@ -130,18 +113,29 @@ const syslogMsgFormat = "<%d>%s: %s: %s"
 
 func (su *SyslogProcessor) Process(entry *LogEntry) {
 	if entry.Priority <= su.GetPriority() {
-		key := (int(su.facility) * 8) + int(entry.Priority)
-		priorityStr := entry.Priority.String()
-		msg := entry.Prefix + entry.Msg
-		msg = fmt.Sprintf(syslogMsgFormat, key, os.Args[0], priorityStr, msg)
-		su.Dispatcher.Send(msg)
+		su.processUnfiltered(entry)
+	}
+}
+
+func (su *SyslogProcessor) processUnfiltered(entry *LogEntry) {
+	key := (int(su.facility) * 8) + int(entry.Priority)
+	priorityStr := entry.Priority.String()
+	msg := entry.Prefix + entry.Msg
+	if sd := appendErrorSD(nil, entry.Fields); len(sd) > 0 {
+		msg = string(sd) + " " + msg
 	}
+	msg = fmt.Sprintf(syslogMsgFormat, key, os.Args[0], priorityStr, msg)
+	su.Dispatcher.Send(msg)
 }
 
 // Initializer for the SyslogProcessor
 //
 func NewSyslogProcessorAt(network, addy string, f Facility, p Priority) (LogProcessor, error) {
-	sw, err := DialSyslog(network, addy)
+	return NewSyslogProcessorWithOptions(network, addy, f, p, ReconnectOptions{})
+}
+
+func NewSyslogProcessorWithOptions(network, addy string, f Facility, p Priority, opts ReconnectOptions) (LogProcessor, error) {
+	sw, err := DialSyslogWithOptions(network, addy, opts)
 	if err != nil {
 		errMsg := fmt.Sprintf("Error in NewSyslogProcessor: %s", err.Error())
 		return nil, errors.New(errMsg)
@@ -149,7 +143,7 @@ func NewSyslogProcessorAt(network, addy string, f Facility, p Priority) (LogProc
 
 	dsp := NewLogDispatcher(sw)
 	defaultProcessor := NewProcessor(p, dsp, true).(*DefaultProcessor)
-	return &SyslogProcessor{DefaultProcessor: defaultProcessor, facility: f}, nil
+	return &SyslogProcessor{DefaultProcessor: defaultProcessor, facility: f, writer: sw}, nil
 }
 
 func NewSyslogProcessor(f Facility, p Priority) (LogProcessor, error) {
@@ -171,3 +171,28 @@ func TestConcurrentSyslogWrite(t *testing.T) {
 		t.Fatalf(errmsg, total_routines, len(log_lines))
 	}
 }
+
+func TestSyslogReconnectsToConfiguredAddress(t *testing.T) {
+	msgChan := make(chan string)
+	servAddy, err := startServer(msgChan)
+	if err != nil {
+		t.Fatalf("Couldn't start syslog listener:  %s", err.Error())
+	}
+
+	sw, err := DialSyslogWithOptions("udp", servAddy, ReconnectOptions{})
+	if err != nil {
+		t.Fatalf("Couldn't connect to syslog:  %s", err.Error())
+	}
+	defer sw.Close()
+
+	// Force a reconnect, which used to always go to the local socket.
+	sw.mu.Lock()
+	sw.disconnect()
+	sw.mu.Unlock()
+	sw.reconnect()
+
+	sw.Write([]byte("reconnected\n"))
+	if rcvd := <-msgChan; rcvd != "reconnected\n" {
+		t.Errorf("Expected message at %s after reconnecting, got '%s'", servAddy, rcvd)
+	}
+}
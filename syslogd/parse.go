@@ -0,0 +1,227 @@
+// The syslogd package implements a small syslog server which hands the
+// messages it receives to golog LogProcessors.  It's meant for tests which
+// need to check what a logger sent to syslog, and for small hosts which want
+// to use golog itself as a syslog relay into files.
+//
+// Both RFC 3164 (BSD) and RFC 5424 messages are understood, including the
+// "<PRI>program: PRIORITY: message" lines written by golog's SyslogProcessor.
+//
+package syslogd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moovweb/golog"
+)
+
+// Which RFC a message was parsed as.
+type Format int
+
+const (
+	RFC3164 Format = iota
+	RFC5424
+)
+
+func (f Format) String() string {
+	if f == RFC5424 {
+		return "RFC5424"
+	}
+	return "RFC3164"
+}
+
+// ****************************************************************************
+// A syslog message broken up into its parts.  Fields missing from the
+// message (or given as "-" in RFC 5424) are left empty.
+//
+type Message struct {
+	Format         Format
+	Facility       golog.Facility
+	Priority       golog.Priority // The syslog severity.
+	Timestamp      time.Time      // When the message was received if it had none.
+	Hostname       string
+	AppName        string // The TAG of RFC 3164 messages.
+	ProcID         string
+	MsgID          string
+	StructuredData string // Raw SD-ELEMENTs, RFC 5424 only.
+	Msg            string // Payload, without any trailing newline.
+}
+
+// Turn the message into a LogEntry which can be handed to any LogProcessor.
+// The hostname and application, when known, become the entry's prefix.
+func (m *Message) Entry() *golog.LogEntry {
+	prefix := ""
+	if m.Hostname != "" {
+		prefix = m.Hostname + " "
+	}
+	if m.AppName != "" {
+		prefix += m.AppName
+		if m.ProcID != "" {
+			prefix += "[" + m.ProcID + "]"
+		}
+		prefix += ": "
+	}
+
+	return &golog.LogEntry{
+		Prefix:   prefix,
+		Priority: m.Priority,
+		Msg:      m.Msg + "\n",
+		Created:  m.Timestamp,
+	}
+}
+
+var (
+	errNoPriority  = errors.New("syslogd: message doesn't start with <PRI>")
+	errBadPriority = errors.New("syslogd: invalid PRI value")
+	errTruncated   = errors.New("syslogd: truncated RFC 5424 header")
+)
+
+// Parse a single syslog message.  Anything that starts with a valid <PRI>
+// is accepted; an RFC 3164 message with no timestamp or hostname (as golog
+// writes them) simply leaves those fields empty.
+func Parse(data []byte) (*Message, error) {
+	line := strings.TrimRight(string(data), "\r\n\x00")
+
+	pri, rest, err := parsePriority(line)
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{
+		Facility: golog.Facility(pri / 8),
+		Priority: golog.Priority(pri % 8),
+	}
+
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' {
+		if sp := strings.IndexByte(rest, ' '); sp > 0 && isDigits(rest[:sp]) {
+			m.Format = RFC5424
+			err = parse5424(m, rest[sp+1:])
+			return m, err
+		}
+	}
+	parse3164(m, rest)
+	return m, nil
+}
+
+func parsePriority(line string) (pri int, rest string, err error) {
+	if len(line) < 3 || line[0] != '<' {
+		return 0, "", errNoPriority
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 || !isDigits(line[1:end]) {
+		return 0, "", errNoPriority
+	}
+	pri, _ = strconv.Atoi(line[1:end])
+	if pri > 191 {
+		return 0, "", errBadPriority
+	}
+	return pri, line[end+1:], nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+func parse3164(m *Message, rest string) {
+	m.Timestamp = time.Now()
+	if len(rest) >= len(time.Stamp) {
+		if ts, err := time.ParseInLocation(time.Stamp, rest[:len(time.Stamp)], time.Local); err == nil {
+			now := m.Timestamp
+			m.Timestamp = ts.AddDate(now.Year(), 0, 0)
+			// A December message received in January is from last year.
+			if m.Timestamp.After(now.Add(24 * time.Hour)) {
+				m.Timestamp = m.Timestamp.AddDate(-1, 0, 0)
+			}
+			rest = strings.TrimLeft(rest[len(time.Stamp):], " ")
+			if sp := strings.IndexByte(rest, ' '); sp > 0 && !strings.ContainsAny(rest[:sp], ":[") {
+				m.Hostname, rest = rest[:sp], rest[sp+1:]
+			}
+		}
+	}
+
+	// The tag is a single word ended by ':' or '[pid]:'.
+	if colon := strings.Index(rest, ": "); colon > 0 && !strings.ContainsRune(rest[:colon], ' ') {
+		tag := rest[:colon]
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			m.ProcID = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		m.AppName, rest = tag, rest[colon+2:]
+	} else if strings.HasSuffix(rest, ":") && !strings.ContainsRune(rest, ' ') {
+		m.AppName, rest = rest[:len(rest)-1], ""
+	}
+	m.Msg = rest
+}
+
+// VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP SD [SP MSG]
+// We get called with everything after VERSION SP.
+func parse5424(m *Message, rest string) error {
+	var fields [5]string
+	for i := range fields {
+		sp := strings.IndexByte(rest, ' ')
+		if sp < 0 {
+			return errTruncated
+		}
+		fields[i], rest = rest[:sp], rest[sp+1:]
+		if fields[i] == "-" {
+			fields[i] = ""
+		}
+	}
+
+	m.Timestamp = time.Now()
+	if fields[0] != "" {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return err
+		}
+		m.Timestamp = ts
+	}
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = fields[1], fields[2], fields[3], fields[4]
+
+	sd, rest, err := splitStructuredData(rest)
+	if err != nil {
+		return err
+	}
+	m.StructuredData = sd
+	m.Msg = strings.TrimPrefix(rest, "\xef\xbb\xbf")
+	return nil
+}
+
+// Split off the STRUCTURED-DATA part, which is either "-" or a run of
+// [id param="value" ...] elements where values may contain escaped
+// '"', '\' and ']'.
+func splitStructuredData(rest string) (sd, msg string, err error) {
+	if strings.HasPrefix(rest, "-") {
+		return "", strings.TrimPrefix(rest[1:], " "), nil
+	}
+
+	i := 0
+	for i < len(rest) && rest[i] == '[' {
+		inQuotes := false
+		for i++; i < len(rest); i++ {
+			c := rest[i]
+			if inQuotes && c == '\\' {
+				i++
+			} else if c == '"' {
+				inQuotes = !inQuotes
+			} else if c == ']' && !inQuotes {
+				break
+			}
+		}
+		if i >= len(rest) {
+			return "", "", errTruncated
+		}
+		i++
+	}
+	if i == 0 {
+		return "", "", errTruncated
+	}
+	return rest[:i], strings.TrimPrefix(rest[i:], " "), nil
+}
@@ -0,0 +1,63 @@
+package syslogd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moovweb/golog"
+)
+
+func TestParse3164(t *testing.T) {
+	m, err := Parse([]byte("<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err.Error())
+	}
+	if m.Format != RFC3164 || m.Facility != 4 || m.Priority != golog.LOG_CRIT {
+		t.Errorf("Unexpected format/facility/priority: %s %d %s", m.Format, m.Facility, m.Priority)
+	}
+	if m.Hostname != "mymachine" || m.AppName != "su" || m.ProcID != "230" {
+		t.Errorf("Unexpected header: host=%q app=%q pid=%q", m.Hostname, m.AppName, m.ProcID)
+	}
+	if m.Msg != "'su root' failed for lonvick on /dev/pts/8" {
+		t.Errorf("Unexpected msg: %q", m.Msg)
+	}
+	if m.Timestamp.Month() != time.October || m.Timestamp.Day() != 11 || m.Timestamp.Hour() != 22 {
+		t.Errorf("Unexpected timestamp: %s", m.Timestamp)
+	}
+
+	entry := m.Entry()
+	if entry.Prefix != "mymachine su[230]: " || entry.Priority != golog.LOG_CRIT {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+}
+
+func TestParse5424(t *testing.T) {
+	raw := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventID="1011" note="a \] b"] BOMAn application event log entry...`
+	m, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err.Error())
+	}
+	if m.Format != RFC5424 || m.Facility != golog.Facility(20) || m.Priority != golog.LOG_NOTICE {
+		t.Errorf("Unexpected format/facility/priority: %s %d %s", m.Format, m.Facility, m.Priority)
+	}
+	if m.Hostname != "mymachine.example.com" || m.AppName != "evntslog" || m.ProcID != "" || m.MsgID != "ID47" {
+		t.Errorf("Unexpected header: %+v", m)
+	}
+	if m.StructuredData != `[exampleSDID@32473 iut="3" eventID="1011" note="a \] b"]` {
+		t.Errorf("Unexpected structured data: %q", m.StructuredData)
+	}
+	if m.Msg != "BOMAn application event log entry..." {
+		t.Errorf("Unexpected msg: %q", m.Msg)
+	}
+	if !m.Timestamp.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)) {
+		t.Errorf("Unexpected timestamp: %s", m.Timestamp)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, raw := range []string{"", "no priority", "<>x", "<999>x", "<13>1 2003-10-11T22:14:15Z host"} {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Errorf("Expected an error parsing %q", raw)
+		}
+	}
+}
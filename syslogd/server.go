@@ -0,0 +1,257 @@
+package syslogd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/moovweb/golog"
+)
+
+const maxDatagramSize = 64 * 1024
+
+var errBadFrame = errors.New("syslogd: malformed or oversized stream frame")
+
+// ****************************************************************************
+// The Server listens for syslog messages on any number of UDP, TCP and unix
+// sockets.  Every message is parsed, passed to Handler if one is set, and
+// then given to Processor as a LogEntry, so relaying syslog into a file is
+// only a matter of using a file processor.
+//
+// These fields must be set before the first Listen call.
+//
+type Server struct {
+	Processor golog.LogProcessor // Where received messages are logged, may be nil.
+	Handler   func(*Message)     // Called with every message parsed, may be nil.
+	ErrorFunc func(error)        // Called with messages that couldn't be parsed, may be nil.
+
+	mu        sync.Mutex
+	listeners []io.Closer
+	conns     map[net.Conn]bool
+	wg        sync.WaitGroup
+}
+
+func NewServer(processor golog.LogProcessor) *Server {
+	return &Server{Processor: processor}
+}
+
+// Listen for datagrams on a UDP address, such as "127.0.0.1:514" or
+// "localhost:0".  The address actually bound to is returned.
+func (s *Server) ListenUDP(addr string) (net.Addr, error) {
+	return s.listenPacket("udp", addr)
+}
+
+// Listen for datagrams on a unix socket, as /dev/log does.
+func (s *Server) ListenUnixgram(path string) (net.Addr, error) {
+	return s.listenPacket("unixgram", path)
+}
+
+// Listen for stream connections on a TCP address.  Messages are framed with
+// either octet counting or a trailing newline (RFC 6587).
+func (s *Server) ListenTCP(addr string) (net.Addr, error) {
+	return s.listenStream("tcp", addr)
+}
+
+// Listen for stream connections on a unix socket.
+func (s *Server) ListenUnix(path string) (net.Addr, error) {
+	return s.listenStream("unix", path)
+}
+
+// Stop listening, close any open connections, and wait for every message
+// already received to be handled.  The Processor itself isn't closed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	var err error
+	for _, l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.listeners, s.conns = nil, nil
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) listenPacket(network, addr string) (net.Addr, error) {
+	if network == "unixgram" {
+		os.Remove(addr)
+	}
+	pc, err := net.ListenPacket(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	s.track(pc)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			s.handle(buf[:n])
+		}
+	}()
+	return pc.LocalAddr(), nil
+}
+
+func (s *Server) listenStream(network, addr string) (net.Addr, error) {
+	if network == "unix" {
+		os.Remove(addr)
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	s.track(l)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if !s.trackConn(c) {
+				c.Close()
+				return
+			}
+			s.wg.Add(1)
+			go s.serveStream(c)
+		}
+	}()
+	return l.Addr(), nil
+}
+
+func (s *Server) serveStream(c net.Conn) {
+	defer s.wg.Done()
+	defer s.untrackConn(c)
+
+	r := bufio.NewReader(c)
+	for {
+		frame, err := readFrame(r)
+		if len(frame) > 0 {
+			s.handle(frame)
+		}
+		if err == errBadFrame && s.ErrorFunc != nil {
+			s.ErrorFunc(err)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read one message off a stream.  Octet counted frames ("42 <13>...") are
+// recognized by their leading digit, anything else is read up to a newline
+// or NUL byte.  Frames can't be larger than a datagram, and a client
+// sending one gets errBadFrame and is dropped, rather than making us hold
+// on to everything it sends.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		n := 0
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c == ' ' {
+				break
+			}
+			if c < '0' || c > '9' {
+				return nil, errBadFrame
+			}
+			if n = n*10 + int(c-'0'); n > maxDatagramSize {
+				return nil, errBadFrame
+			}
+		}
+		frame := make([]byte, n)
+		_, err = io.ReadFull(r, frame)
+		return frame, err
+	}
+
+	var frame []byte
+	for {
+		// Look for the terminator in whatever is buffered, reading more
+		// only when nothing is.
+		if r.Buffered() == 0 {
+			if _, err := r.Peek(1); err != nil {
+				return frame, err
+			}
+		}
+		buf, _ := r.Peek(r.Buffered())
+		end := bytes.IndexAny(buf, "\n\x00")
+		if end < 0 {
+			end = len(buf)
+		}
+		if len(frame)+end > maxDatagramSize {
+			return nil, errBadFrame
+		}
+		frame = append(frame, buf[:end]...)
+		if end < len(buf) {
+			r.Discard(end + 1)
+			return frame, nil
+		}
+		r.Discard(end)
+	}
+}
+
+func (s *Server) handle(data []byte) {
+	m, err := Parse(data)
+	if err != nil {
+		if s.ErrorFunc != nil {
+			s.ErrorFunc(err)
+		}
+		return
+	}
+
+	if s.Handler != nil {
+		s.Handler(m)
+	}
+	if s.Processor != nil {
+		s.Processor.Process(m.Entry())
+	}
+}
+
+func (s *Server) track(l io.Closer) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, l)
+	s.mu.Unlock()
+}
+
+func (s *Server) trackConn(c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listeners == nil {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = map[net.Conn]bool{}
+	}
+	s.conns[c] = true
+	return true
+}
+
+func (s *Server) untrackConn(c net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	c.Close()
+}
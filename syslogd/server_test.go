@@ -0,0 +1,157 @@
+package syslogd
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moovweb/golog"
+)
+
+func startCapture(t *testing.T) (*Server, chan *Message) {
+	msgs := make(chan *Message, 64)
+	s := NewServer(nil)
+	s.Handler = func(m *Message) { msgs <- m }
+	return s, msgs
+}
+
+func nextMessage(msgs chan *Message, t *testing.T) *Message {
+	select {
+	case m := <-msgs:
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for a syslog message")
+	}
+	return nil
+}
+
+func checkGologMessages(network, addr string, msgs chan *Message, t *testing.T) {
+	proc, err := golog.NewSyslogProcessorAt(network, addr, golog.LOCAL3, golog.LOG_DEBUG)
+	if err != nil {
+		t.Fatalf("Couldn't create syslog processor: %s", err.Error())
+	}
+	logger := golog.NewLogger("syslogd_test: ")
+	logger.AddProcessor("syslog", proc)
+	defer logger.Close()
+
+	for _, p := range golog.Priorities() {
+		logger.Logf(p, "Hey, listen...")
+		m := nextMessage(msgs, t)
+		if m.Facility != golog.LOCAL3 || m.Priority != p {
+			t.Errorf("Expected facility %d priority %s, got %d %s", golog.LOCAL3, p, m.Facility, m.Priority)
+		}
+		expected := p.String() + ": syslogd_test: Hey, listen..."
+		if m.Msg != expected || m.AppName != os.Args[0] {
+			t.Errorf("Unexpected message.\nExpected %s: %s\nResult   %s: %s", os.Args[0], expected, m.AppName, m.Msg)
+		}
+	}
+}
+
+func TestServerUDP(t *testing.T) {
+	s, msgs := startCapture(t)
+	defer s.Close()
+	addr, err := s.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't listen on udp: %s", err.Error())
+	}
+	checkGologMessages("udp", addr.String(), msgs, t)
+}
+
+func TestServerTCP(t *testing.T) {
+	s, msgs := startCapture(t)
+	defer s.Close()
+	addr, err := s.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't listen on tcp: %s", err.Error())
+	}
+	checkGologMessages("tcp", addr.String(), msgs, t)
+}
+
+func TestServerUnixgram(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslogd_test")
+	if err != nil {
+		t.Fatalf("Couldn't create tmp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	s, msgs := startCapture(t)
+	defer s.Close()
+	path := filepath.Join(dir, "log")
+	if _, err := s.ListenUnixgram(path); err != nil {
+		t.Fatalf("Couldn't listen on unixgram: %s", err.Error())
+	}
+	checkGologMessages("unixgram", path, msgs, t)
+}
+
+func TestServerRelaysToProcessor(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "syslogd_relay")
+	if err != nil {
+		t.Fatalf("Couldn't open tmp file: %s", err.Error())
+	}
+	defer os.Remove(tmpfile.Name())
+
+	s := NewServer(golog.NewProcessorFromWriter(golog.LOG_INFO, tmpfile, true))
+	defer s.Close()
+	addr, err := s.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't listen on tcp: %s", err.Error())
+	}
+
+	proc, err := golog.NewSyslogProcessorAt("tcp", addr.String(), golog.LOCAL0, golog.LOG_DEBUG)
+	if err != nil {
+		t.Fatalf("Couldn't create syslog processor: %s", err.Error())
+	}
+	logger := golog.NewLogger("")
+	logger.AddProcessor("syslog", proc)
+	defer logger.Close()
+	logger.Debugf("filtered out by the relay")
+	logger.Warningf("relayed")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		data, _ := ioutil.ReadFile(tmpfile.Name())
+		if strings.Contains(string(data), "WARNING: relayed") {
+			if strings.Contains(string(data), "filtered out") {
+				t.Errorf("Relay wrote a message below its priority: %s", data)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Relayed message never made it to the file")
+}
+
+func TestReadFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("5 <13>a<13>b\n<13>c\x00<13>d"))
+	for _, expected := range []string{"<13>a", "<13>b", "<13>c", "<13>d"} {
+		frame, err := readFrame(r)
+		if string(frame) != expected || (err != nil && err != io.EOF) {
+			t.Errorf("Expected frame %q, got %q, %v", expected, frame, err)
+		}
+	}
+}
+
+func TestReadFrameBounded(t *testing.T) {
+	oversized := []string{
+		strings.Repeat("9", 1<<20), // A count which never ends.
+		"99999999 <13>",            // A count above the limit.
+		"12a <13>",                 // Not a count.
+		strings.Repeat("x", maxDatagramSize+1) + "\n", // No terminator in sight.
+	}
+	for _, data := range oversized {
+		r := bufio.NewReader(strings.NewReader(data))
+		if frame, err := readFrame(r); err != errBadFrame || frame != nil {
+			t.Errorf("Expected errBadFrame for a %d byte stream, got %d bytes, %v", len(data), len(frame), err)
+		}
+	}
+
+	data := strings.Repeat("x", maxDatagramSize) + "\n"
+	if frame, err := readFrame(bufio.NewReader(strings.NewReader(data))); len(frame) != maxDatagramSize || err != nil {
+		t.Errorf("Expected a frame at the limit to be read, got %d bytes, %v", len(frame), err)
+	}
+}
@@ -0,0 +1,122 @@
+// Log Processor for streaming newline delimited JSON over TCP, as read by
+// log shippers such as Fluent Bit or Vector.
+//
+package golog
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	defaultTcpDialTimeout  = 5 * time.Second
+	defaultTcpWriteTimeout = 5 * time.Second
+)
+
+// Options for the TcpWriter.  The zero value is usable.
+type TcpOptions struct {
+	ReconnectOptions
+
+	DialTimeout  time.Duration // Defaults to 5s.
+	WriteTimeout time.Duration // Deadline for each write, defaults to 5s.
+	KeepAlive    time.Duration // TCP keepalive period, negative turns it off.
+}
+
+// ****************************************************************************
+// The TcpWriter streams messages over a TCP connection.  Every write gets a
+// deadline so that a stuck shipper can't stall the log channel; a write that
+// fails or times out is handled like any other disconnect, and messages are
+// buffered until the shipper is back (see reconnectWriter).
+//
+type TcpWriter struct {
+	*reconnectWriter
+}
+
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (dc *deadlineConn) Write(data []byte) (n int, err error) {
+	dc.SetWriteDeadline(time.Now().Add(dc.timeout))
+	return dc.Conn.Write(data)
+}
+
+func DialTcp(host string) (sock io.WriteCloser, err error) {
+	tw, err := DialTcpWithOptions(host, TcpOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return tw, nil
+}
+
+func DialTcpWithOptions(host string, opts TcpOptions) (*TcpWriter, error) {
+	rw, err := newReconnectWriter(tcpDialer(host, opts), opts.ReconnectOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &TcpWriter{reconnectWriter: rw}, nil
+}
+
+// Get a function dialing host with the timeouts of opts, whose connections
+// give every write a deadline.
+func tcpDialer(host string, opts TcpOptions) func() (io.WriteCloser, error) {
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = defaultTcpDialTimeout
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = defaultTcpWriteTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: opts.KeepAlive}
+	return func() (io.WriteCloser, error) {
+		conn, err := dialer.Dial("tcp", host)
+		if err != nil {
+			return nil, err
+		}
+		return &deadlineConn{Conn: conn, timeout: opts.WriteTimeout}, nil
+	}
+}
+
+// ****************************************************************************
+// The TcpProcessor writes each entry as one line of JSON (see
+// appendJSONEntry).  Its TimeFormat defaults to RFC 3339.
+//
+type TcpProcessor struct {
+	*DefaultProcessor
+	writer *TcpWriter
+}
+
+func (tp *TcpProcessor) Process(entry *LogEntry) {
+	if entry.Priority <= tp.GetPriority() {
+		tp.processUnfiltered(entry)
+	}
+}
+
+func (tp *TcpProcessor) processUnfiltered(entry *LogEntry) {
+	config := tp.loadConfig()
+	lm := tp.Dispatcher.message(entry.Priority)
+	lm.msg = appendJSONEntry(lm.msg, entry, config.timeFormat)
+	tp.Dispatcher.dispatch(lm)
+}
+
+// Get the counters of the underlying TcpWriter, such as how many messages
+// were dropped while the shipper was unreachable.
+func (tp *TcpProcessor) Stats() WriterStats {
+	return tp.writer.Stats()
+}
+
+func NewTcpProcessorAt(host string, p Priority) (LogProcessor, error) {
+	return NewTcpProcessorWithOptions(host, p, TcpOptions{})
+}
+
+func NewTcpProcessorWithOptions(host string, p Priority, opts TcpOptions) (LogProcessor, error) {
+	tw, err := DialTcpWithOptions(host, opts)
+	if err != nil {
+		return nil, err
+	}
+	dsp := NewLogDispatcher(tw)
+	defaultProcessor := NewProcessor(p, dsp, true).(*DefaultProcessor)
+	return &TcpProcessor{DefaultProcessor: defaultProcessor, writer: tw}, nil
+}
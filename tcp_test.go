@@ -0,0 +1,113 @@
+package golog
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+type jsonLine struct {
+	Time   string `json:"time"`
+	Level  string `json:"level"`
+	Prefix string `json:"prefix"`
+	Msg    string `json:"msg"`
+}
+
+func readJSONLine(r *bufio.Reader, t *testing.T) jsonLine {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Couldn't read line: %s", err.Error())
+	}
+	var jl jsonLine
+	if err = json.Unmarshal(line, &jl); err != nil {
+		t.Fatalf("Invalid JSON line %q: %s", line, err.Error())
+	}
+	return jl
+}
+
+func acceptTcp(l net.Listener, t *testing.T) (net.Conn, *bufio.Reader) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Couldn't accept connection: %s", err.Error())
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	return conn, bufio.NewReader(conn)
+}
+
+func TestTcpJSONLines(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start tcp listener: %s", err.Error())
+	}
+	defer l.Close()
+
+	proc, err := NewTcpProcessorAt(l.Addr().String(), LOG_INFO)
+	if err != nil {
+		t.Fatalf("Couldn't create tcp processor: %s", err.Error())
+	}
+	logger := NewLogger("tcp_test: ")
+	logger.AddProcessor("tcp", proc)
+	defer logger.Close()
+
+	conn, r := acceptTcp(l, t)
+	defer conn.Close()
+
+	logger.Debugf("filtered")
+	logger.Warningf("say \"%s\"", "hi")
+	jl := readJSONLine(r, t)
+	if jl.Level != "WARNING" || jl.Prefix != "tcp_test: " || jl.Msg != `say "hi"` {
+		t.Errorf("Unexpected line: %+v", jl)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, jl.Time); err != nil {
+		t.Errorf("Unexpected time %q: %s", jl.Time, err.Error())
+	}
+}
+
+func TestTcpReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start tcp listener: %s", err.Error())
+	}
+	addr := l.Addr().String()
+
+	opts := TcpOptions{ReconnectOptions: ReconnectOptions{MinBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}}
+	proc, err := NewTcpProcessorWithOptions(addr, LOG_INFO, opts)
+	if err != nil {
+		t.Fatalf("Couldn't create tcp processor: %s", err.Error())
+	}
+	logger := NewLogger("")
+	logger.AddProcessor("tcp", proc)
+	defer logger.Close()
+
+	conn, r := acceptTcp(l, t)
+	logger.Infof("before")
+	if jl := readJSONLine(r, t); jl.Msg != "before" {
+		t.Fatalf("Unexpected line: %+v", jl)
+	}
+
+	// Take the shipper down.  The first write after that may still be
+	// accepted by the kernel, the following ones can't be.
+	conn.Close()
+	l.Close()
+	for i := 0; i < 5; i++ {
+		logger.Infof("during")
+		time.Sleep(20 * time.Millisecond)
+	}
+	if stats := proc.(*TcpProcessor).Stats(); stats.Buffered == 0 {
+		t.Fatalf("Expected messages to be buffered during the outage: %+v", stats)
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Couldn't restart tcp listener: %s", err.Error())
+	}
+	defer l.Close()
+	conn, r = acceptTcp(l, t)
+	defer conn.Close()
+
+	if jl := readJSONLine(r, t); jl.Msg != "during" {
+		t.Errorf("Expected buffered messages to be replayed, got %+v", jl)
+	}
+}
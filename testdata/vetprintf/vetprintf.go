@@ -0,0 +1,34 @@
+// Calls with mismatched printf arguments, which go vet has to report for
+// every printf style method of golog.  Checked by TestVetPrintfWrappers.
+//
+package vetprintf
+
+import (
+	"context"
+
+	"github.com/moovweb/golog"
+)
+
+func calls(l *golog.Logger, ctx context.Context) {
+	l.Logf(golog.LOG_INFO, "%d", "str")
+	l.Plogf(golog.LOG_INFO, "prefix: ", "%d", "str")
+	l.Debugf("%d", "str")
+	l.Infof("%d", "str")
+	l.Noticef("%d", "str")
+	l.Warningf("%d", "str")
+	l.Errorf("%s %s", 1)
+	l.Criticalf("%d", "str")
+	l.Alertf("%d", "str")
+	l.Emergencyf("%d", "str")
+	l.Fatalf("%d", "str")
+	l.Panicf("%d", "str")
+	l.LogfCtx(ctx, golog.LOG_INFO, "%d", "str")
+	l.DebugfCtx(ctx, "%d", "str")
+	l.InfofCtx(ctx, "%d", "str")
+	l.NoticefCtx(ctx, "%d", "str")
+	l.WarningfCtx(ctx, "%d", "str")
+	l.ErrorfCtx(ctx, "%s %s", 1)
+	l.CriticalfCtx(ctx, "%d", "str")
+	l.AlertfCtx(ctx, "%d", "str")
+	l.EmergencyfCtx(ctx, "%d", "str")
+}
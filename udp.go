@@ -1,53 +1,310 @@
 package golog
 
-import "io"
-import "net"
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
+// Largest payload a UDP datagram can carry over IPv4.
+const maxUdpDatagramSize = 65507
+
+// Environment variable NewUdpProcessor reads its target address from.
+const UdpAddrEnv = "GOLOG_UDP_ADDR"
+
+var errNoUdpAddr = errors.New("golog: no UDP target address, set " + UdpAddrEnv + " or use NewUdpProcessorAt")
+
+// Swapped out by tests to simulate a host changing addresses.
+var resolveUdpAddr = net.ResolveUDPAddr
+
+// What to do with a message that doesn't fit in a single datagram.
+type UdpOversize int
+
+const (
+	UdpTruncate UdpOversize = iota // Send the beginning, drop the rest.
+	UdpSplit                       // Send it over as many datagrams as needed.
+)
+
+// Options for the UdpWriter.  The zero value sends every message on its own
+// as before, truncated to the largest datagram UDP allows.
+type UdpOptions struct {
+	Network   string // "udp" (the default), "udp4" or "udp6".
+	LocalAddr string // Address to send from, such as "10.0.0.5:0".
+
+	// Hop limit for datagrams sent to a multicast group, the system
+	// default (usually 1) is used if unset.
+	MulticastTTL int
+
+	// If set, the target host name is looked up again at this interval,
+	// and we switch over to its new address if it changed.
+	ResolveInterval time.Duration
+
+	MaxDatagramSize int         // Defaults to 65507, use ~1472 to avoid fragmentation.
+	Oversize        UdpOversize // Handling of messages above MaxDatagramSize.
+
+	// If set, messages are packed together, newline separated, into
+	// datagrams of up to MaxDatagramSize bytes.  A partially filled
+	// datagram is sent at most BatchInterval after its first message.
+	BatchInterval time.Duration
+}
+
+// Counters kept by the UdpWriter.
+type UdpStats struct {
+	Packets   uint64 // Datagrams sent.
+	Truncated uint64 // Messages cut down to MaxDatagramSize.
+	Split     uint64 // Messages sent over several datagrams.
+	Errors    uint64 // Datagrams the socket refused.
+}
+
+// ****************************************************************************
+// The UdpWriter sends messages as datagrams, keeping every datagram within
+// MaxDatagramSize and optionally batching small messages together.
+//
 type UdpWriter struct {
-	noConn io.WriteCloser
+	noConn  io.WriteCloser
+	host    string
+	raddr   *net.UDPAddr
+	laddr   *net.UDPAddr
+	opts    UdpOptions
+	mu      sync.Mutex
+	batch   []byte
+	flusher *time.Timer
+	done    chan bool // Closed to stop re-resolving the host.
+	stats   UdpStats  // Only accessed atomically.
 }
 
 func (nw *UdpWriter) Close() error {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	if nw.flusher != nil {
+		nw.flusher.Stop()
+		nw.flusher = nil
+	}
+	if nw.done != nil {
+		close(nw.done)
+		nw.done = nil
+	}
+	nw.flush()
 	return nw.noConn.Close()
 }
 
 func (nw *UdpWriter) Write(data []byte) (n int, err error) {
-	return nw.noConn.Write(data)
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	// A truncated message still counts as written, truncating it is how
+	// the writer was asked to handle it.
+	n = len(data)
+	max := nw.opts.MaxDatagramSize
+	if len(data) > max {
+		if nw.opts.Oversize == UdpSplit {
+			atomic.AddUint64(&nw.stats.Split, 1)
+			nw.flush()
+			for start := 0; start < len(data); start += max {
+				end := start + max
+				if end > len(data) {
+					end = len(data)
+				}
+				if err = nw.send(data[start:end]); err != nil {
+					return start, err
+				}
+			}
+			return n, nil
+		}
+		atomic.AddUint64(&nw.stats.Truncated, 1)
+		data = data[:max]
+	}
+
+	if nw.opts.BatchInterval <= 0 {
+		if err = nw.send(data); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+
+	needsNewline := len(data) == 0 || data[len(data)-1] != '\n'
+	size := len(data)
+	if needsNewline && size < max {
+		size++
+	}
+	if len(nw.batch)+size > max {
+		nw.flush()
+	}
+	nw.batch = append(nw.batch, data...)
+	if needsNewline && size > len(data) {
+		nw.batch = append(nw.batch, '\n')
+	}
+	if nw.flusher == nil {
+		nw.flusher = time.AfterFunc(nw.opts.BatchInterval, nw.flushLater)
+	}
+	return n, nil
+}
+
+// Get a snapshot of the writer's counters.
+func (nw *UdpWriter) Stats() UdpStats {
+	return UdpStats{
+		Packets:   atomic.LoadUint64(&nw.stats.Packets),
+		Truncated: atomic.LoadUint64(&nw.stats.Truncated),
+		Split:     atomic.LoadUint64(&nw.stats.Split),
+		Errors:    atomic.LoadUint64(&nw.stats.Errors),
+	}
+}
+
+func (nw *UdpWriter) flushLater() {
+	nw.mu.Lock()
+	nw.flusher = nil
+	nw.flush()
+	nw.mu.Unlock()
+}
+
+// Send whatever is batched up.  Must be called with nw.mu held.
+func (nw *UdpWriter) flush() {
+	if len(nw.batch) > 0 {
+		nw.send(nw.batch)
+		nw.batch = nw.batch[:0]
+	}
+}
+
+func (nw *UdpWriter) send(datagram []byte) error {
+	if _, err := nw.noConn.Write(datagram); err != nil {
+		atomic.AddUint64(&nw.stats.Errors, 1)
+		return err
+	}
+	atomic.AddUint64(&nw.stats.Packets, 1)
+	return nil
 }
 
 func DialUdp(host string) (sock io.WriteCloser, err error) {
-	addr, err := net.ResolveUDPAddr("udp", host)
+	dw, err := DialUdpWithOptions(host, UdpOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return dw, nil
+}
+
+// Look the host up again, and if its address changed, move over to it.
+func (nw *UdpWriter) resolve() {
+	raddr, err := resolveUdpAddr(nw.opts.Network, nw.host)
 	if err != nil {
 		return
 	}
-	conn, err := net.DialUDP("udp", nil, addr)
+
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	if nw.done == nil || raddr.String() == nw.raddr.String() {
+		return
+	}
+	conn, err := dialUdpConn(nw.opts, nw.laddr, raddr)
 	if err != nil {
 		return
 	}
-	return &UdpWriter{noConn: conn}, nil
+	nw.noConn.Close()
+	nw.noConn, nw.raddr = conn, raddr
+}
+
+func (nw *UdpWriter) resolveEvery(interval time.Duration, done chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			nw.resolve()
+		case <-done:
+			return
+		}
+	}
+}
+
+func dialUdpConn(opts UdpOptions, laddr, raddr *net.UDPAddr) (*net.UDPConn, error) {
+	conn, err := net.DialUDP(opts.Network, laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MulticastTTL > 0 && raddr.IP.IsMulticast() {
+		if err = setMulticastTTL(conn, raddr.IP.To4() == nil, opts.MulticastTTL); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func DialUdpWithOptions(host string, opts UdpOptions) (*UdpWriter, error) {
+	if opts.MaxDatagramSize <= 0 || opts.MaxDatagramSize > maxUdpDatagramSize {
+		opts.MaxDatagramSize = maxUdpDatagramSize
+	}
+	if opts.Network == "" {
+		opts.Network = "udp"
+	}
+
+	var laddr *net.UDPAddr
+	if opts.LocalAddr != "" {
+		var err error
+		if laddr, err = net.ResolveUDPAddr(opts.Network, opts.LocalAddr); err != nil {
+			return nil, err
+		}
+	}
+	raddr, err := resolveUdpAddr(opts.Network, host)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialUdpConn(opts, laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+
+	nw := &UdpWriter{noConn: conn, host: host, raddr: raddr, laddr: laddr, opts: opts}
+	if opts.ResolveInterval > 0 {
+		nw.done = make(chan bool)
+		go nw.resolveEvery(opts.ResolveInterval, nw.done)
+	}
+	return nw, nil
 }
 
 type UdpProcessor struct {
 	*DefaultProcessor
+	writer *UdpWriter
 }
 
 func (np *UdpProcessor) Process(entry *LogEntry) {
 	if entry.Priority <= np.GetPriority() {
-		msg := entry.Msg
-		np.Dispatcher.Send(msg)
+		np.processUnfiltered(entry)
 	}
 }
 
+func (np *UdpProcessor) processUnfiltered(entry *LogEntry) {
+	np.Dispatcher.Send(entry.Msg)
+}
+
+// Get the counters of the underlying UdpWriter.
+func (np *UdpProcessor) Stats() UdpStats {
+	return np.writer.Stats()
+}
+
 func NewUdpProcessorAt(host string, p Priority) (LogProcessor, error) {
-	dw, err := DialUdp(host)
+	return NewUdpProcessorWithOptions(host, p, UdpOptions{})
+}
+
+func NewUdpProcessorWithOptions(host string, p Priority, opts UdpOptions) (LogProcessor, error) {
+	dw, err := DialUdpWithOptions(host, opts)
 	if err != nil {
 		return nil, err
 	}
 	dsp := NewLogDispatcher(dw)
 	defaultProcessor := NewProcessor(p, dsp, true).(*DefaultProcessor)
-	return &UdpProcessor{DefaultProcessor: defaultProcessor}, nil
+	return &UdpProcessor{DefaultProcessor: defaultProcessor, writer: dw}, nil
 }
 
+// Create a UdpProcessor sending to the address found in the GOLOG_UDP_ADDR
+// environment variable.
 func NewUdpProcessor(p Priority) (LogProcessor, error) {
-	return NewUdpProcessorAt("localhost:0", p)
+	host := os.Getenv(UdpAddrEnv)
+	if host == "" {
+		return nil, errNoUdpAddr
+	}
+	return NewUdpProcessorAt(host, p)
 }
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package golog
+
+import (
+	"errors"
+	"net"
+)
+
+func setMulticastTTL(conn *net.UDPConn, ipv6 bool, ttl int) error {
+	return errors.New("golog: setting the multicast TTL isn't supported on this platform")
+}
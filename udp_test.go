@@ -6,6 +6,7 @@ import "time"
 import "sync"
 import "strings"
 import "sort"
+import "os"
 
 func checkUdpOutput(result string, p Priority, prefix, msg string, t *testing.T) {
 	expected := msg
@@ -143,3 +144,187 @@ func TestConcurrentUdpWrite(t *testing.T) {
 		t.Fatalf(errmsg, total_routines, len(log_lines))
 	}
 }
+
+// Read datagrams off c until n have arrived or we time out.
+func readUdpDatagrams(c net.PacketConn, n int, t *testing.T) []string {
+	var datagrams []string
+	var buf [65536]byte
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(datagrams) < n {
+		size, _, err := c.ReadFrom(buf[0:])
+		if err != nil {
+			t.Fatalf("Expected %d datagrams, only got %d: %s", n, len(datagrams), err.Error())
+		}
+		datagrams = append(datagrams, string(buf[0:size]))
+	}
+	return datagrams
+}
+
+func dialUdpTestWriter(opts UdpOptions, t *testing.T) (net.PacketConn, *UdpWriter) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start udp listener:  %s", err.Error())
+	}
+	w, err := DialUdpWithOptions(c.LocalAddr().String(), opts)
+	if err != nil {
+		t.Fatalf("Couldn't connect to udp:  %s", err.Error())
+	}
+	return c, w
+}
+
+func TestUdpTruncate(t *testing.T) {
+	c, w := dialUdpTestWriter(UdpOptions{MaxDatagramSize: 8}, t)
+	defer c.Close()
+	defer w.Close()
+
+	if n, err := w.Write([]byte("0123456789\n")); n != 11 || err != nil {
+		t.Errorf("Truncating should count as a full write, got %d, %v", n, err)
+	}
+	if got := readUdpDatagrams(c, 1, t); got[0] != "01234567" {
+		t.Errorf("Expected a truncated datagram, got '%s'", got[0])
+	}
+	if stats := w.Stats(); stats.Packets != 1 || stats.Truncated != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestUdpTruncateBatched(t *testing.T) {
+	opts := UdpOptions{MaxDatagramSize: 8, BatchInterval: 10 * time.Millisecond}
+	c, w := dialUdpTestWriter(opts, t)
+	defer c.Close()
+	defer w.Close()
+
+	if n, err := w.Write([]byte("0123456789\n")); n != 11 || err != nil {
+		t.Errorf("Truncating should count as a full write, got %d, %v", n, err)
+	}
+	if got := readUdpDatagrams(c, 1, t); got[0] != "01234567" {
+		t.Errorf("Expected a truncated datagram, got '%s'", got[0])
+	}
+}
+
+func TestUdpSplit(t *testing.T) {
+	c, w := dialUdpTestWriter(UdpOptions{MaxDatagramSize: 4, Oversize: UdpSplit}, t)
+	defer c.Close()
+	defer w.Close()
+
+	w.Write([]byte("0123456789\n"))
+	got := strings.Join(readUdpDatagrams(c, 3, t), "|")
+	if got != "0123|4567|89\n" {
+		t.Errorf("Unexpected split datagrams '%s'", got)
+	}
+	if stats := w.Stats(); stats.Packets != 3 || stats.Split != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestUdpBatching(t *testing.T) {
+	opts := UdpOptions{MaxDatagramSize: 12, BatchInterval: 20 * time.Millisecond}
+	c, w := dialUdpTestWriter(opts, t)
+	defer c.Close()
+	defer w.Close()
+
+	for _, msg := range []string{"one\n", "two", "three\n", "four\n"} {
+		w.Write([]byte(msg))
+	}
+	got := readUdpDatagrams(c, 2, t)
+	if got[0] != "one\ntwo\n" || got[1] != "three\nfour\n" {
+		t.Errorf("Unexpected batches %q", got)
+	}
+	// The last batch is counted once its write returned, which may be
+	// after it was received.
+	waitFor(t, "2 packets to be counted", func() bool {
+		return w.Stats().Packets == 2
+	})
+}
+
+func TestNewUdpProcessorFromEnv(t *testing.T) {
+	defer os.Setenv(UdpAddrEnv, os.Getenv(UdpAddrEnv))
+
+	os.Unsetenv(UdpAddrEnv)
+	if _, err := NewUdpProcessor(LOG_DEBUG); err == nil {
+		t.Errorf("Expected an error without %s set", UdpAddrEnv)
+	}
+
+	os.Setenv(UdpAddrEnv, "localhost:8675")
+	proc, err := NewUdpProcessor(LOG_DEBUG)
+	if err != nil {
+		t.Fatalf("NewUdpProcessor failed with %s set:  %s", UdpAddrEnv, err.Error())
+	}
+	proc.Close()
+}
+
+func TestUdpIPv6LocalAddr(t *testing.T) {
+	c, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 isn't available:  %s", err.Error())
+	}
+	defer c.Close()
+
+	opts := UdpOptions{Network: "udp6", LocalAddr: "[::1]:0"}
+	w, err := DialUdpWithOptions(c.LocalAddr().String(), opts)
+	if err != nil {
+		t.Fatalf("Couldn't connect to udp6:  %s", err.Error())
+	}
+	defer w.Close()
+
+	w.Write([]byte("over ipv6\n"))
+	if got := readUdpDatagrams(c, 1, t); got[0] != "over ipv6\n" {
+		t.Errorf("Unexpected datagram '%s'", got[0])
+	}
+}
+
+
+func TestUdpReResolve(t *testing.T) {
+	first, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start udp listener:  %s", err.Error())
+	}
+	defer first.Close()
+	second, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Couldn't start udp listener:  %s", err.Error())
+	}
+	defer second.Close()
+
+	// "collector" resolves to whichever listener target points at.
+	var mu sync.Mutex
+	target := first.LocalAddr().String()
+	resolveUdpAddr = func(network, host string) (*net.UDPAddr, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return net.ResolveUDPAddr(network, target)
+	}
+	defer func() { resolveUdpAddr = net.ResolveUDPAddr }()
+
+	w, err := DialUdpWithOptions("collector", UdpOptions{ResolveInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Couldn't connect to udp:  %s", err.Error())
+	}
+	defer w.Close()
+
+	w.Write([]byte("first\n"))
+	readUdpDatagrams(first, 1, t)
+
+	mu.Lock()
+	target = second.LocalAddr().String()
+	mu.Unlock()
+
+	// Keep writing until the writer notices the move.
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	stop := make(chan bool)
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				w.Write([]byte("second\n"))
+			}
+		}
+	}()
+	var buf [64]byte
+	if n, _, err := second.ReadFrom(buf[0:]); err != nil || string(buf[0:n]) != "second\n" {
+		t.Errorf("Writer never moved over to the new address")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package golog
+
+import (
+	"net"
+	"syscall"
+)
+
+func setMulticastTTL(conn *net.UDPConn, ipv6 bool, ttl int) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		if ipv6 {
+			serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, ttl)
+		} else {
+			serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, ttl)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package golog
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestUdpMulticastTTL(t *testing.T) {
+	w, err := DialUdpWithOptions("239.255.0.1:8675", UdpOptions{MulticastTTL: 2})
+	if err != nil {
+		t.Fatalf("Couldn't connect to multicast group:  %s", err.Error())
+	}
+	defer w.Close()
+
+	raw, err := w.noConn.(*net.UDPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("Couldn't get the raw connection: %s", err.Error())
+	}
+	var ttl int
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		ttl, serr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL)
+	})
+	if err == nil {
+		err = serr
+	}
+	if err != nil {
+		t.Fatalf("Couldn't read the multicast TTL: %s", err.Error())
+	}
+	if ttl != 2 {
+		t.Errorf("Expected a multicast TTL of 2, got %d", ttl)
+	}
+}